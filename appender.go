@@ -4,6 +4,7 @@ import (
 	"fmt"
 	"io"
 	"os"
+	"sync"
 )
 
 // Interface for implementing custom appenders.
@@ -18,49 +19,348 @@ type Appender interface {
 	Id() string
 }
 
-// Representing stdout appender.
+// Representing console appender, which writes logs to stdout, stderr,
+// or any other writer. If output is terminal, levels are colored and
+// time and logger name are dimmed (look at NewConsole for overriding it).
+// Appender can be used from several goroutines.
 type Stdout struct {
+	id         string
 	dateformat string
-	buf        []byte
-	out        io.Writer
+	names      NameLayout
+	out        consoleWriter
+
+	// in split mode WARNING and more severe logs are written here
+	errOut *consoleWriter
+
+	// error in configuration, it is reported with first log
+	err      error
+	reported bool
+
+	// guards names, reporting of err and writes to output
+	mu sync.Mutex
+}
+
+// writer of console appender, colors and format depend
+// on whether writer is terminal
+type consoleWriter struct {
+	w     io.Writer
+	color bool
+	json  bool
 }
 
 var (
-	instance *Stdout
-	out      io.Writer
+	instance       *Stdout
+	stderrInstance *Stdout
+	out            io.Writer
 )
 
-// Appending logs to stdout.
+// Appending logs to console. Lines are formatted in pooled buffers,
+// so appender is locked only while line is written.
 func (s *Stdout) Append(log Log) {
-	s.buf = s.buf[:0]
-	s.buf = append(s.buf, fmt.Sprintf("%s %s [%s]: %s",
-		log.Level.String()[:4],
-		log.Logger.Name,
-		log.Time.Format(s.dateformat),
-		log.Message)...)
+	cw := &s.out
+	if s.errOut != nil && log.Level <= WARNING {
+		cw = s.errOut
+	}
+
+	// unsupported values are replaced with defaults, so log is written anyway
+	if err := s.unreportedErr(); err != nil {
+		fmt.Println(err.Error())
+		if log.Logger != nil && log.Logger.DoPanic {
+			panic(err)
+		}
+	}
+
+	bp := getBuffer()
+	defer putBuffer(bp)
+
+	if cw.json {
+		line, err := log.MarshalJSON()
+		if err != nil {
+			fmt.Println(err.Error())
+			if log.Logger != nil && log.Logger.DoPanic {
+				panic(err)
+			}
+			return
+		}
+		*bp = append(*bp, line...)
+	} else {
+		s.mu.Lock()
+		names := s.names
+		s.mu.Unlock()
+
+		*bp = appendText(*bp, log, s.dateformat, cw.color, names)
+	}
+
+	*bp = append(*bp, '\n')
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	cw.w.Write(*bp)
+}
+
+func (s *Stdout) unreportedErr() error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.reported {
+		return nil
+	}
+	s.reported = true
+	return s.err
+}
+
+// Returns error in configuration of appender, unsupported values
+// are replaced with defaults. Error is also reported with first log.
+func (s *Stdout) Err() error {
+	return s.err
+}
+
+// buffers of formatted lines, they are shared by all console appenders
+var bufferPool = sync.Pool{
+	New: func() interface{} {
+		buf := make([]byte, 0, 256)
+		return &buf
+	},
+}
+
+// buffers which grew bigger are not kept, so one long line
+// doesn't hold memory forever
+const maxPooledBuffer = 64 << 10
+
+func getBuffer() *[]byte {
+	return bufferPool.Get().(*[]byte)
+}
+
+func putBuffer(bp *[]byte) {
+	if cap(*bp) > maxPooledBuffer {
+		return
+	}
+	*bp = (*bp)[:0]
+	bufferPool.Put(bp)
+}
+
+// Date format used by stdout appender.
+const StdoutDateFormat = "2006-01-02 15:04:05"
+
+// Appends log formatted as one line of stdout appender
+// output (without new line) to buf and returns extended buffer.
+// Logger name is shown in full.
+func AppendText(buf []byte, log Log) []byte {
+	return appendText(buf, log, StdoutDateFormat, false, FullName)
+}
+
+// Appends log to buf like AppendText, but with colors of stdout appender
+// output in terminal: level is colored, time and logger name are dimmed.
+func AppendColorText(buf []byte, log Log) []byte {
+	return appendText(buf, log, StdoutDateFormat, true, FullName)
+}
+
+// ColorEnabled reports whether output written to w is colored by default:
+// w is terminal, unless NO_COLOR or FORCE_COLOR environment variables
+// override it. Stdout appender uses it when color is "auto".
+func ColorEnabled(w io.Writer) bool {
+	return colorEnabled(isTerminal(w))
+}
+
+// ANSI colors of levels, errors and more severe levels are red
+var levelColors = map[LogLevel]string{
+	EMERGENCY: "\x1b[1;31m",
+	ALERT:     "\x1b[1;31m",
+	CRITICAL:  "\x1b[1;31m",
+	ERROR:     "\x1b[31m",
+	WARNING:   "\x1b[33m",
+	NOTICE:    "\x1b[36m",
+	INFO:      "\x1b[32m",
+	DEBUG:     "\x1b[34m",
+}
+
+const (
+	colorDim   = "\x1b[2m"
+	colorReset = "\x1b[0m"
+)
+
+func appendText(buf []byte, log Log, dateformat string, color bool, names NameLayout) []byte {
+	level := log.Level.String()
+	if len(level) > 4 {
+		level = level[:4]
+	}
+
+	if names == nil {
+		names = FullName
+	}
+
+	if color {
+		buf = append(buf, levelColors[log.Level]...)
+		buf = append(buf, level...)
+		buf = append(buf, colorReset+" "+colorDim...)
+	} else {
+		buf = append(buf, level...)
+		buf = append(buf, ' ')
+	}
+
+	if log.Logger != nil {
+		buf = names.AppendName(buf, log.Logger.Name)
+	}
+
+	buf = append(buf, " ["...)
+	buf = log.Time.AppendFormat(buf, dateformat)
+	buf = append(buf, ']')
+	if color {
+		buf = append(buf, colorReset...)
+	}
+	buf = append(buf, ": "...)
+	buf = append(buf, log.Message...)
 
 	if log.Data != nil {
-		s.buf = append(s.buf, ' ')
-		s.buf = append(s.buf, fmt.Sprint(log.Data...)...)
+		buf = append(buf, ' ')
+		buf = fmt.Append(buf, log.Data...)
 	}
-	s.buf = append(s.buf, '\n')
-	s.out.Write(s.buf)
+	return buf
 }
 
-// Getting Id of stdout appender
-// Id of default stdout appender is "github.com/ildus/golog/stdout"
+// Getting Id of console appender.
+// Id of stdout appender is "github.com/ildus/golog/stdout", Id of stderr
+// appender is "github.com/ildus/golog/stderr", and appenders for other
+// writers have Id "github.com/ildus/golog/console".
 func (s *Stdout) Id() string {
-	return "github.com/ildus/golog/stdout"
+	return s.id
 }
 
-// Function for creating and returning new stdout appender instance.
+// Function for creating and returning shared stdout appender instance,
+// which is enabled on loggers by default. Output is colored if stdout
+// is terminal, it can be overridden with NO_COLOR and FORCE_COLOR
+// environment variables.
 func StdoutAppender() *Stdout {
 	if instance == nil {
-		instance = &Stdout{
-			dateformat: "2006-01-02 15:04:05",
-			out:        os.Stdout,
-		}
+		instance = NewStdout(Conf{})
 	}
 
 	return instance
 }
+
+// Function for creating and returning shared stderr appender instance.
+func StderrAppender() *Stdout {
+	if stderrInstance == nil {
+		stderrInstance = NewConsole(os.Stderr, Conf{})
+		stderrInstance.id = "github.com/ildus/golog/stderr"
+	}
+
+	return stderrInstance
+}
+
+// Function for creating stdout appender which isn't shared with other
+// loggers. Besides keys described in NewConsole, it supports "split" key,
+// if it is "true", WARNING and more severe logs are written to stderr.
+func NewStdout(cnf Conf) *Stdout {
+	s := NewConsole(os.Stdout, cnf)
+	s.id = "github.com/ildus/golog/stdout"
+
+	switch cnf["split"] {
+	case "", "false":
+	case "true":
+		// errors are the same as errors of stdout writer
+		errOut, _ := newConsoleWriter(os.Stderr, cnf)
+		s.errOut = &errOut
+	default:
+		if s.err == nil {
+			s.err = fmt.Errorf("Unsupported split %q, use true or false", cnf["split"])
+		}
+	}
+
+	return s
+}
+
+// Function for creating console appender which writes to w.
+// Supported configuration keys:
+//
+//	color  - "auto" (default) colors output if it is terminal, unless
+//	         NO_COLOR environment variable is set, or FORCE_COLOR is set
+//	         (to other value than "0" or "false"), "always" or "never"
+//	format - "text" (default) is the usual stdout layout, "json" writes
+//	         documents described in SchemaVersion, "auto" writes text
+//	         to terminal and JSON if output is piped
+//	name   - layout of logger names in text: "segments" (default, look
+//	         at SegmentLayout), "{20}" shortens names to 20 characters
+//	         like logback (look at LogbackLayout), "full" shows names
+//	         as they are
+//
+// Unsupported values are replaced with defaults, error is returned from Err
+// and reported with first log.
+func NewConsole(w io.Writer, cnf Conf) *Stdout {
+	out, err := newConsoleWriter(w, cnf)
+
+	names, nameErr := parseNameLayout(cnf["name"])
+	if nameErr != nil {
+		names, _ = parseNameLayout("")
+		if err == nil {
+			err = nameErr
+		}
+	}
+
+	return &Stdout{
+		id:         "github.com/ildus/golog/console",
+		dateformat: StdoutDateFormat,
+		names:      names,
+		out:        out,
+		err:        err,
+	}
+}
+
+// SetNameLayout changes layout of logger names.
+func (s *Stdout) SetNameLayout(names NameLayout) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.names = names
+}
+
+func newConsoleWriter(w io.Writer, cnf Conf) (consoleWriter, error) {
+	cw := consoleWriter{w: w}
+	tty := isTerminal(w)
+
+	var err error
+
+	switch cnf["color"] {
+	case "", "auto":
+		cw.color = colorEnabled(tty)
+	case "always":
+		cw.color = true
+	case "never":
+	default:
+		err = fmt.Errorf("Unsupported color %q, use auto, always or never", cnf["color"])
+		cw.color = colorEnabled(tty)
+	}
+
+	switch cnf["format"] {
+	case "", "text":
+	case "json":
+		cw.json = true
+	case "auto":
+		cw.json = !tty
+	default:
+		if err == nil {
+			err = fmt.Errorf("Unsupported format %q, use text, json or auto", cnf["format"])
+		}
+	}
+
+	return cw, err
+}
+
+// isTerminal reports whether w is file opened on terminal
+func isTerminal(w io.Writer) bool {
+	f, ok := w.(*os.File)
+	return ok && terminal(f)
+}
+
+// colorEnabled decides whether output should be colored by default,
+// FORCE_COLOR overrides NO_COLOR (https://no-color.org)
+func colorEnabled(tty bool) bool {
+	if force := os.Getenv("FORCE_COLOR"); force != "" {
+		return force != "0" && force != "false"
+	}
+	if os.Getenv("NO_COLOR") != "" {
+		return false
+	}
+	return tty
+}
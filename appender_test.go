@@ -0,0 +1,207 @@
+package golog
+
+import (
+	"bytes"
+	"os"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func consoleTestLog() Log {
+	return Log{
+		Time:    time.Date(2009, 11, 10, 23, 0, 0, 0, time.UTC),
+		Message: "some msg",
+		Level:   ERROR,
+		Logger:  &Logger{Name: "console"},
+		Data:    []interface{}{map[string]int{"size": 10}},
+	}
+}
+
+func TestStdoutColor(t *testing.T) {
+	buf := new(bytes.Buffer)
+	s := NewConsole(buf, Conf{"color": "always"})
+	s.Append(consoleTestLog())
+
+	assert.Equal(t, "\x1b[31mERRO\x1b[0m \x1b[2mconsole [2009-11-10 23:00:00]\x1b[0m: some msg map[size:10]\n",
+		buf.String())
+}
+
+func TestStdoutJSON(t *testing.T) {
+	buf := new(bytes.Buffer)
+	s := NewConsole(buf, Conf{"format": "json"})
+	s.Append(consoleTestLog())
+
+	assert.Equal(t, `{"v":1,"time":"2009-11-10T23:00:00Z","level":3,"level_name":"ERROR",`+
+		`"message":"some msg","pid":0,"logger":{"name":"console"},"data":[{"size":10}]}`+"\n", buf.String())
+}
+
+func TestNewStdout(t *testing.T) {
+	t.Setenv("NO_COLOR", "")
+	t.Setenv("FORCE_COLOR", "")
+
+	// output of tests isn't terminal
+	s := NewStdout(Conf{})
+	assert.False(t, s.out.color)
+	assert.False(t, s.out.json)
+	assert.Equal(t, os.Stdout, s.out.w)
+	assert.Nil(t, s.errOut)
+	assert.Equal(t, "github.com/ildus/golog/stdout", s.Id())
+
+	s = NewStdout(Conf{"color": "always", "format": "auto"})
+	assert.True(t, s.out.color)
+	assert.True(t, s.out.json)
+
+	s = NewStdout(Conf{"color": "never", "format": "json", "split": "true"})
+	assert.False(t, s.out.color)
+	assert.True(t, s.out.json)
+	assert.Equal(t, os.Stderr, s.errOut.w)
+	assert.True(t, s.errOut.json)
+
+	// shared appender isn't changed
+	assert.True(t, StdoutAppender() == StdoutAppender())
+	assert.False(t, StdoutAppender() == NewStdout(Conf{}))
+
+	assert.Nil(t, NewStdout(Conf{}).Err())
+	assert.NotNil(t, NewStdout(Conf{"split": "maybe"}).Err())
+}
+
+func TestConsoleConfError(t *testing.T) {
+	for _, cnf := range []Conf{{"color": "pink"}, {"format": "xml"}, {"name": "short"}} {
+		buf := new(bytes.Buffer)
+		s := NewConsole(buf, cnf)
+		assert.NotNil(t, s.Err(), "%v", cnf)
+
+		// error isn't written to configured output,
+		// and defaults are used instead of wrong values
+		logger := &Logger{Name: "conf", Level: DEBUG}
+		logger.Enable(s)
+		logger.Info("info")
+		assert.Regexp(t, `^INFO conf    \[.+\]: info\n$`, buf.String())
+
+		// error is reported only with first log
+		logger.DoPanic = true
+		assert.NotPanics(t, func() { logger.Info("info") })
+	}
+
+	logger := &Logger{Name: "conf", Level: DEBUG, DoPanic: true}
+	logger.Enable(NewConsole(new(bytes.Buffer), Conf{"color": "pink"}))
+	assert.Panics(t, func() { logger.Info("info") })
+}
+
+func TestConsoleSplit(t *testing.T) {
+	out, errOut := new(bytes.Buffer), new(bytes.Buffer)
+	s := NewConsole(out, Conf{"color": "never"})
+	errWriter, err := newConsoleWriter(errOut, Conf{"color": "always"})
+	assert.Nil(t, err)
+	s.errOut = &errWriter
+
+	logger := &Logger{Name: "split", Level: DEBUG}
+	logger.Enable(s)
+
+	logger.Info("info")
+	logger.Warn("warning")
+	logger.Error("error")
+
+	assert.Regexp(t, `^INFO split   \[.+\]: info\n$`, out.String())
+	assert.Equal(t, 2, bytes.Count(errOut.Bytes(), []byte("\n")))
+	assert.Contains(t, errOut.String(), "\x1b[33mWARN\x1b[0m")
+	assert.Contains(t, errOut.String(), "\x1b[31mERRO\x1b[0m")
+}
+
+func TestConsoleConcurrent(t *testing.T) {
+	buf := &lockedBuffer{}
+	s := NewConsole(buf, Conf{"color": "never"})
+
+	var wg sync.WaitGroup
+	for i := 0; i < 4; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for j := 0; j < 100; j++ {
+				s.Append(consoleTestLog())
+			}
+		}()
+	}
+	wg.Wait()
+
+	line := "ERRO console [2009-11-10 23:00:00]: some msg map[size:10]\n"
+	assert.Equal(t, strings.Repeat(line, 400), buf.String())
+}
+
+// buffer which can be written from several goroutines
+type lockedBuffer struct {
+	mu  sync.Mutex
+	buf bytes.Buffer
+}
+
+func (b *lockedBuffer) Write(p []byte) (int, error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return b.buf.Write(p)
+}
+
+func (b *lockedBuffer) String() string {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return b.buf.String()
+}
+
+func TestStderrAppender(t *testing.T) {
+	s := StderrAppender()
+	assert.True(t, s == StderrAppender())
+	assert.Equal(t, os.Stderr, s.out.w)
+	assert.Equal(t, "github.com/ildus/golog/stderr", s.Id())
+	assert.Equal(t, "github.com/ildus/golog/console", NewConsole(new(bytes.Buffer), Conf{}).Id())
+
+	// stdout and stderr appenders can be enabled together
+	logger := &Logger{Name: "both"}
+	logger.Enable(StdoutAppender())
+	logger.Enable(s)
+	logger.Disable(StdoutAppender().Id())
+	assert.Equal(t, []Appender{s}, logger.appenders)
+}
+
+func TestColorEnabled(t *testing.T) {
+	for _, c := range []struct {
+		noColor, forceColor string
+		tty, enabled        bool
+	}{
+		{"", "", true, true},
+		{"", "", false, false},
+		{"1", "", true, false},
+		{"", "1", false, true},
+		{"1", "1", false, true},
+		{"", "0", true, false},
+		{"", "false", true, false},
+	} {
+		t.Setenv("NO_COLOR", c.noColor)
+		t.Setenv("FORCE_COLOR", c.forceColor)
+		assert.Equal(t, c.enabled, colorEnabled(c.tty), "%+v", c)
+	}
+
+	t.Setenv("FORCE_COLOR", "1")
+	assert.True(t, NewStdout(Conf{}).out.color)
+	assert.False(t, NewStdout(Conf{"color": "never"}).out.color)
+}
+
+func TestIsTerminal(t *testing.T) {
+	assert.False(t, isTerminal(new(bytes.Buffer)))
+
+	r, w, err := os.Pipe()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer r.Close()
+	defer w.Close()
+	assert.False(t, isTerminal(w))
+
+	// terminal of process, if it has one
+	if tty, err := os.OpenFile("/dev/tty", os.O_RDWR, 0); err == nil {
+		defer tty.Close()
+		assert.True(t, isTerminal(tty))
+	}
+}
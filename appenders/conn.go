@@ -0,0 +1,351 @@
+package appenders
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"math/rand"
+	"net"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/ildus/golog"
+)
+
+const (
+	defaultMinBackoff  = 100 * time.Millisecond
+	defaultMaxBackoff  = 30 * time.Second
+	defaultDialTimeout = 5 * time.Second
+)
+
+// Conn is network connection used by appenders which send logs to
+// remote services. Connection is dialed lazily on first write and
+// redialed with exponential backoff after failures. Every Write is
+// treated as one message, so while remote side is unavailable messages
+// can be kept in bounded memory buffer and sent after reconnect.
+type Conn struct {
+	// network and address as accepted by net.Dial,
+	// "tcp" is used if network is empty
+	Network string
+	Addr    string
+
+	// if set, connection will be wrapped in TLS client
+	TLSConfig *tls.Config
+
+	// delay before first redial, it is doubled after each failure
+	// until it reaches MaxBackoff
+	MinBackoff time.Duration
+	MaxBackoff time.Duration
+
+	DialTimeout time.Duration
+
+	// maximum number of bytes kept while remote side is unavailable,
+	// if zero messages are not buffered and write errors are returned
+	BufferSize int
+
+	mu       sync.Mutex
+	conn     net.Conn
+	err      error
+	failures uint
+	nextDial time.Time
+	buffer   messageBuffer
+}
+
+// Connection is not made because last dial failed not long ago.
+var ErrBackoff = errors.New("connection is in backoff after failed dial")
+
+// Write sends p as one message. If message can't be sent, but it fits into
+// buffer, it will be sent later and no error is returned. Message which
+// was sent only partly is never resent, because its rest would break
+// framing on new connection, short count is returned with error instead.
+func (c *Conn) Write(p []byte) (int, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if c.err != nil {
+		return 0, c.err
+	}
+
+	var err error
+
+	// established connection can be already broken, in that case
+	// we try to redial once without waiting for backoff
+	for attempt := 0; attempt < 2; attempt++ {
+		if err = c.connect(); err != nil {
+			break
+		}
+
+		if err = c.buffer.flush(c.send); err == nil {
+			var n int
+			if n, err = c.send(p); err == nil {
+				return len(p), nil
+			}
+			if n > 0 {
+				c.conn.Close()
+				c.conn = nil
+				return n, err
+			}
+		}
+
+		c.conn.Close()
+		c.conn = nil
+	}
+
+	if c.buffer.push(p, c.BufferSize) {
+		return len(p), nil
+	}
+	return 0, err
+}
+
+func (c *Conn) send(p []byte) (int, error) {
+	return c.conn.Write(p)
+}
+
+// Close closes current connection and discards buffered messages.
+func (c *Conn) Close() error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	c.buffer.reset()
+
+	if c.conn == nil {
+		return nil
+	}
+
+	err := c.conn.Close()
+	c.conn = nil
+	return err
+}
+
+// Dropped returns number of messages which were lost
+// because buffer was full.
+func (c *Conn) Dropped() int64 {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	return c.buffer.dropped
+}
+
+// Healthy reports if connection is established or can be dialed now.
+// Unhealthy connection waits for backoff after failed dial.
+func (c *Conn) Healthy() bool {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	return c.err == nil && (c.conn != nil || !time.Now().Before(c.nextDial))
+}
+
+func (c *Conn) connect() error {
+	if c.conn != nil {
+		return nil
+	}
+
+	if time.Now().Before(c.nextDial) {
+		return ErrBackoff
+	}
+
+	network := c.Network
+	if network == "" {
+		network = "tcp"
+	}
+
+	timeout := c.DialTimeout
+	if timeout == 0 {
+		timeout = defaultDialTimeout
+	}
+
+	var (
+		conn net.Conn
+		err  error
+	)
+
+	dialer := &net.Dialer{Timeout: timeout}
+	if c.TLSConfig != nil {
+		conn, err = tls.DialWithDialer(dialer, network, c.Addr, c.TLSConfig)
+	} else {
+		conn, err = dialer.Dial(network, c.Addr)
+	}
+
+	if err != nil {
+		c.failures++
+		c.nextDial = time.Now().Add(c.backoff(c.failures))
+		return fmt.Errorf("Error dialing host %q: %s", c.Addr, err)
+	}
+
+	c.conn = conn
+	c.failures = 0
+	return nil
+}
+
+// backoff returns random delay from [d/2, d], where d is MinBackoff
+// doubled for every failure after first one, limited by MaxBackoff.
+func (c *Conn) backoff(failures uint) time.Duration {
+	min, max := c.MinBackoff, c.MaxBackoff
+	if min <= 0 {
+		min = defaultMinBackoff
+	}
+	if max <= 0 {
+		max = defaultMaxBackoff
+	}
+
+	// delay is doubled only while it can't overflow
+	delay := min
+	for i := uint(1); i < failures && delay < max; i++ {
+		if delay > max/2 {
+			delay = max
+			break
+		}
+		delay *= 2
+	}
+	if delay > max {
+		delay = max
+	}
+
+	half := delay / 2
+	return half + time.Duration(rand.Int63n(int64(delay-half)+1))
+}
+
+// messageBuffer keeps messages while remote side is unavailable
+type messageBuffer struct {
+	msgs    [][]byte
+	size    int
+	dropped int64
+}
+
+// flush sends buffered messages, messages which weren't sent stay in buffer,
+// partly sent message is dropped
+func (b *messageBuffer) flush(send func([]byte) (int, error)) error {
+	for len(b.msgs) > 0 {
+		msg := b.msgs[0]
+		if n, err := send(msg); err != nil {
+			if n > 0 {
+				b.msgs = b.msgs[1:]
+				b.size -= len(msg)
+				b.dropped++
+			}
+			return err
+		}
+
+		b.msgs = b.msgs[1:]
+		b.size -= len(msg)
+	}
+
+	b.msgs = nil
+	return nil
+}
+
+// push copies message into buffer, oldest messages are dropped to make space
+func (b *messageBuffer) push(p []byte, limit int) bool {
+	if len(p) > limit {
+		return false
+	}
+
+	for b.size+len(p) > limit {
+		b.size -= len(b.msgs[0])
+		b.msgs = b.msgs[1:]
+		b.dropped++
+	}
+
+	msg := make([]byte, len(p))
+	copy(msg, p)
+
+	b.msgs = append(b.msgs, msg)
+	b.size += len(msg)
+	return true
+}
+
+func (b *messageBuffer) reset() {
+	b.msgs = nil
+	b.size = 0
+}
+
+// NewConn creates connection using appender configuration. Supported keys:
+//
+//	proto           - network ("tcp", "udp", "unix"...), default is "tcp"
+//	addr            - remote address
+//	min_backoff     - delay before first redial (e.g. "100ms")
+//	max_backoff     - maximum delay between redials (e.g. "30s")
+//	dial_timeout    - timeout for one dial (e.g. "5s")
+//	buffer_size     - bytes kept in memory while remote side is unavailable
+//	tls             - "true" to use TLS
+//	tls_ca          - path to PEM file with CA certificates
+//	tls_cert        - path to PEM file with client certificate
+//	tls_key         - path to PEM file with client key
+//	tls_server_name - server name used for certificate verification
+//	tls_skip_verify - "true" to skip server certificate verification
+//
+// Errors in configuration are returned from every Write.
+func NewConn(cnf golog.Conf) *Conn {
+	c := &Conn{
+		Network: cnf["proto"],
+		Addr:    cnf["addr"],
+	}
+
+	c.err = c.configure(cnf)
+	return c
+}
+
+func (c *Conn) configure(cnf golog.Conf) (err error) {
+	if c.MinBackoff, err = confDuration(cnf, "min_backoff"); err != nil {
+		return err
+	}
+	if c.MaxBackoff, err = confDuration(cnf, "max_backoff"); err != nil {
+		return err
+	}
+	if c.DialTimeout, err = confDuration(cnf, "dial_timeout"); err != nil {
+		return err
+	}
+
+	if val := cnf["buffer_size"]; val != "" {
+		if c.BufferSize, err = strconv.Atoi(val); err != nil {
+			return fmt.Errorf("Invalid buffer_size %q: %s", val, err)
+		}
+	}
+
+	if cnf["tls"] != "true" {
+		return nil
+	}
+
+	c.TLSConfig = &tls.Config{
+		ServerName:         cnf["tls_server_name"],
+		InsecureSkipVerify: cnf["tls_skip_verify"] == "true",
+	}
+
+	if path := cnf["tls_ca"]; path != "" {
+		pem, err := ioutil.ReadFile(path)
+		if err != nil {
+			return fmt.Errorf("Error reading CA certificates: %s", err)
+		}
+
+		c.TLSConfig.RootCAs = x509.NewCertPool()
+		if !c.TLSConfig.RootCAs.AppendCertsFromPEM(pem) {
+			return fmt.Errorf("No CA certificates found in %q", path)
+		}
+	}
+
+	if cnf["tls_cert"] != "" || cnf["tls_key"] != "" {
+		cert, err := tls.LoadX509KeyPair(cnf["tls_cert"], cnf["tls_key"])
+		if err != nil {
+			return fmt.Errorf("Error loading client certificate: %s", err)
+		}
+		c.TLSConfig.Certificates = []tls.Certificate{cert}
+	}
+
+	return nil
+}
+
+func confDuration(cnf golog.Conf, key string) (time.Duration, error) {
+	val := cnf[key]
+	if val == "" {
+		return 0, nil
+	}
+
+	d, err := time.ParseDuration(val)
+	if err != nil {
+		return 0, fmt.Errorf("Invalid %s %q: %s", key, val, err)
+	}
+	return d, nil
+}
@@ -0,0 +1,274 @@
+package appenders
+
+import (
+	"bufio"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"errors"
+	"math/big"
+	"net"
+	"testing"
+	"time"
+
+	"github.com/ildus/golog"
+	"github.com/stretchr/testify/assert"
+)
+
+// returns address which is not listened by anyone
+func unusedAddr(t *testing.T) string {
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	ln.Close()
+	return ln.Addr().String()
+}
+
+func readLines(t *testing.T, ln net.Listener, count int) []string {
+	conn, err := ln.Accept()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer conn.Close()
+
+	conn.SetReadDeadline(time.Now().Add(5 * time.Second))
+	reader := bufio.NewReader(conn)
+
+	var lines []string
+	for i := 0; i < count; i++ {
+		line, err := reader.ReadString('\n')
+		if err != nil {
+			t.Fatal(err)
+		}
+		lines = append(lines, line)
+	}
+	return lines
+}
+
+func TestConnConf(t *testing.T) {
+	conn := NewConn(golog.Conf{
+		"proto":       "udp",
+		"addr":        "127.0.0.1:5565",
+		"min_backoff": "10ms",
+		"max_backoff": "1s",
+		"buffer_size": "1024",
+	})
+
+	assert.Nil(t, conn.err)
+	assert.Equal(t, "udp", conn.Network)
+	assert.Equal(t, 10*time.Millisecond, conn.MinBackoff)
+	assert.Equal(t, time.Second, conn.MaxBackoff)
+	assert.Equal(t, 1024, conn.BufferSize)
+	assert.Nil(t, conn.TLSConfig)
+
+	conn = NewConn(golog.Conf{"max_backoff": "soon"})
+	_, err := conn.Write([]byte("msg"))
+	assert.NotNil(t, err)
+
+	conn = NewConn(golog.Conf{"tls": "true", "tls_ca": "/nonexistent/ca.pem"})
+	_, err = conn.Write([]byte("msg"))
+	assert.NotNil(t, err)
+}
+
+func TestConnBackoff(t *testing.T) {
+	conn := &Conn{MinBackoff: 100 * time.Millisecond, MaxBackoff: time.Second}
+
+	for i := 0; i < 100; i++ {
+		delay := conn.backoff(1)
+		assert.True(t, delay >= 50*time.Millisecond && delay <= 100*time.Millisecond)
+
+		delay = conn.backoff(3)
+		assert.True(t, delay >= 200*time.Millisecond && delay <= 400*time.Millisecond)
+
+		delay = conn.backoff(10)
+		assert.True(t, delay >= 500*time.Millisecond && delay <= time.Second)
+
+		delay = conn.backoff(100)
+		assert.True(t, delay >= 500*time.Millisecond && delay <= time.Second)
+	}
+}
+
+func TestConnBackoffLarge(t *testing.T) {
+	conn := &Conn{MinBackoff: 10 * time.Second, MaxBackoff: time.Hour}
+
+	// shifting MinBackoff would overflow after about 30 failures
+	for failures := uint(1); failures < 200; failures++ {
+		delay := conn.backoff(failures)
+		assert.True(t, delay >= 5*time.Second && delay <= time.Hour)
+	}
+
+	delay := conn.backoff(40)
+	assert.True(t, delay >= 30*time.Minute)
+}
+
+func TestConnWriteError(t *testing.T) {
+	conn := &Conn{Addr: unusedAddr(t), MinBackoff: time.Minute}
+
+	_, err := conn.Write([]byte("msg"))
+	assert.NotNil(t, err)
+	assert.NotEqual(t, ErrBackoff, err)
+
+	// next dial is postponed
+	_, err = conn.Write([]byte("msg"))
+	assert.Equal(t, ErrBackoff, err)
+}
+
+func TestConnReconnect(t *testing.T) {
+	addr := unusedAddr(t)
+	conn := &Conn{Addr: addr, MinBackoff: time.Millisecond, MaxBackoff: time.Millisecond, BufferSize: 1024}
+	defer conn.Close()
+
+	// nobody listens, so messages are kept in buffer
+	n, err := conn.Write([]byte("first\n"))
+	assert.Nil(t, err)
+	assert.Equal(t, 6, n)
+
+	msg := []byte("second\n")
+	_, err = conn.Write(msg)
+	assert.Nil(t, err)
+	copy(msg, "garbage")
+
+	ln, err := net.Listen("tcp", addr)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer ln.Close()
+
+	time.Sleep(5 * time.Millisecond)
+	_, err = conn.Write([]byte("third\n"))
+	assert.Nil(t, err)
+	assert.Equal(t, []string{"first\n", "second\n", "third\n"}, readLines(t, ln, 3))
+
+	// connection is redialed after remote side closes it,
+	// messages written before broken connection is noticed can be lost
+	done := make(chan bool)
+	defer close(done)
+
+	go func() {
+		for {
+			select {
+			case <-done:
+				return
+			default:
+				conn.Write([]byte("again\n"))
+				time.Sleep(time.Millisecond)
+			}
+		}
+	}()
+
+	ln.(*net.TCPListener).SetDeadline(time.Now().Add(5 * time.Second))
+	assert.Equal(t, []string{"again\n"}, readLines(t, ln, 1))
+}
+
+// connection which writes only part of message and fails
+type shortConn struct {
+	net.Conn
+	written []byte
+}
+
+func (c *shortConn) Write(p []byte) (int, error) {
+	n := len(p) / 2
+	c.written = append(c.written, p[:n]...)
+	return n, errors.New("connection reset")
+}
+
+func (c *shortConn) Close() error {
+	return nil
+}
+
+func TestConnShortWrite(t *testing.T) {
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer ln.Close()
+
+	short := &shortConn{}
+	conn := &Conn{Addr: ln.Addr().String(), BufferSize: 1024, conn: short}
+	defer conn.Close()
+
+	// rest of message isn't sent on new connection
+	n, err := conn.Write([]byte("first\n"))
+	assert.Equal(t, 3, n)
+	assert.NotNil(t, err)
+	assert.Equal(t, "fir", string(short.written))
+
+	// partly sent buffered message is dropped
+	short = &shortConn{}
+	conn.conn = short
+	conn.buffer.push([]byte("buffered\n"), conn.BufferSize)
+
+	lines := make(chan []string)
+	go func() {
+		lines <- readLines(t, ln, 1)
+	}()
+
+	_, err = conn.Write([]byte("second\n"))
+	assert.Nil(t, err)
+	assert.Equal(t, []string{"second\n"}, <-lines)
+	assert.Equal(t, "buff", string(short.written))
+	assert.Equal(t, int64(1), conn.Dropped())
+}
+
+func TestConnBufferLimit(t *testing.T) {
+	conn := &Conn{Addr: unusedAddr(t), MinBackoff: time.Minute, BufferSize: 10}
+
+	for _, msg := range []string{"aaaa", "bbbb", "cccc"} {
+		_, err := conn.Write([]byte(msg))
+		assert.Nil(t, err)
+	}
+
+	_, err := conn.Write([]byte("too long message"))
+	assert.Equal(t, ErrBackoff, err)
+
+	assert.Equal(t, int64(1), conn.Dropped())
+	assert.Equal(t, [][]byte{[]byte("bbbb"), []byte("cccc")}, conn.buffer.msgs)
+}
+
+func TestConnTLS(t *testing.T) {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	template := &x509.Certificate{
+		SerialNumber: big.NewInt(1),
+		Subject:      pkix.Name{CommonName: "golog"},
+		NotBefore:    time.Now().Add(-time.Hour),
+		NotAfter:     time.Now().Add(time.Hour),
+		IPAddresses:  []net.IP{net.ParseIP("127.0.0.1")},
+	}
+
+	der, err := x509.CreateCertificate(rand.Reader, template, template, &key.PublicKey, key)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	ln, err := tls.Listen("tcp", "127.0.0.1:0", &tls.Config{
+		Certificates: []tls.Certificate{{Certificate: [][]byte{der}, PrivateKey: key}},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer ln.Close()
+
+	cert, _ := x509.ParseCertificate(der)
+	pool := x509.NewCertPool()
+	pool.AddCert(cert)
+
+	lines := make(chan []string)
+	go func() {
+		lines <- readLines(t, ln, 1)
+	}()
+
+	conn := &Conn{Addr: ln.Addr().String(), TLSConfig: &tls.Config{RootCAs: pool}}
+	defer conn.Close()
+
+	_, err = conn.Write([]byte("secure\n"))
+	assert.Nil(t, err)
+	assert.Equal(t, []string{"secure\n"}, <-lines)
+}
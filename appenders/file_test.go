@@ -3,15 +3,34 @@ package appenders
 import (
 	"encoding/json"
 	"github.com/ildus/golog"
+	"github.com/ildus/golog/logtest"
 	"github.com/stretchr/testify/assert"
 	"io/ioutil"
 	"os"
+	"path/filepath"
 	"testing"
+	"time"
 )
 
 func init() {
 }
 
+// logger with deterministic environment, so its logs always look the same
+func goldenLogger(appender golog.Appender) *golog.Logger {
+	logger := &golog.Logger{Name: "golden", Level: golog.DEBUG, Environment: logtest.NewEnvironment()}
+	logger.Enable(appender)
+	return logger
+}
+
+// golden log encoded as JSON
+const goldenJSON = `{"id":"0124e053-3580-7000-8000-000000000001","v":1,"time":"2009-11-10T23:00:00Z",` +
+	`"level":4,"level_name":"WARNING","message":"card declined","pid":1234,` +
+	`"logger":{"name":"golden"},"data":[{"amount":10}]}`
+
+func logGolden(logger *golog.Logger) {
+	logger.Warn("card declined", map[string]interface{}{"amount": 10})
+}
+
 func TestFileId(t *testing.T) {
 	appender := File(golog.Conf{})
 	assert.Equal(t, "github.com/ildus/golog/appender/file", appender.Id())
@@ -31,8 +50,12 @@ func TestFileAppend(t *testing.T) {
 	})
 
 	log := golog.Log{
+		Time:    time.Date(2009, 11, 10, 23, 0, 0, 5, time.UTC),
 		Message: logtext,
-		// todo: add other
+		Level:   golog.WARNING,
+		Pid:     42,
+		Logger:  &golog.Logger{Name: "files"},
+		Data:    []interface{}{map[string]int{"size": 10}},
 	}
 
 	appender.Append(log)
@@ -46,5 +69,26 @@ func TestFileAppend(t *testing.T) {
 
 	logInstance := &golog.Log{}
 	err = json.Unmarshal(content, &logInstance)
+	assert.Nil(t, err)
 	assert.Equal(t, logtext, logInstance.Message)
+	assert.Equal(t, log.Time, logInstance.Time)
+	assert.Equal(t, golog.WARNING, logInstance.Level)
+	assert.Equal(t, 42, logInstance.Pid)
+	assert.Equal(t, "files", logInstance.Logger.Name)
+	assert.Equal(t, []interface{}{map[string]interface{}{"size": int64(10)}}, logInstance.Data)
+}
+
+func TestFileGolden(t *testing.T) {
+	dir, err := ioutil.TempDir("", "golog-golden")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	logfile := filepath.Join(dir, "golden.log")
+	logGolden(goldenLogger(File(golog.Conf{"path": logfile})))
+
+	content, err := ioutil.ReadFile(logfile)
+	assert.Nil(t, err)
+	assert.Equal(t, goldenJSON+"\n", string(content))
 }
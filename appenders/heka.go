@@ -4,8 +4,8 @@ import (
 	"fmt"
 	"github.com/ildus/golog"
 	"github.com/ildus/golog/heka_emitter"
-	"io"
-	"net"
+	"os"
+	"sync"
 )
 
 type HekaAppender struct {
@@ -13,8 +13,11 @@ type HekaAppender struct {
 	Addr       string
 	EnvVersion string
 	Type       string
-	conn       io.Writer
+	Hostname   string
+	conn       *Pool
+	cnf        golog.Conf
 	emitter    *heka_emitter.ProtobufEmitter
+	mu         sync.Mutex
 }
 
 func (fa *HekaAppender) Id() string {
@@ -22,49 +25,90 @@ func (fa *HekaAppender) Id() string {
 }
 
 func (ha *HekaAppender) Append(log golog.Log) {
-	if ha.conn == nil {
-		if len(ha.Addr) == 0 {
-			fmt.Printf("Missing remote host")
-			return
-		}
-		var err error
-		ha.conn, err = net.Dial(ha.Proto, ha.Addr)
-		if err != nil {
-			fmt.Printf("Error dialing host %q: %s", ha.Addr, err)
-			return
-		}
+	ha.mu.Lock()
+	defer ha.mu.Unlock()
 
-		ha.emitter = heka_emitter.NewProtobufEmitter(ha.conn,
-			ha.EnvVersion, "", log.Logger.Name)
+	err := ha.init()
+	if err == nil {
+		err = ha.emitter.EmitLog(ha.Type, log)
 	}
 
-	// if additional data contains maps or errors collect them into one map
-	var logFields map[string]string
-	if log.Data != nil {
-		for _, item := range log.Data {
-			switch item.(type) {
-			case map[string]string:
-				{
-					for key, val := range item.(map[string]string) {
-						logFields[key] = val
-					}
-				}
-			case error:
-				{
-					logFields["error"] = item.(error).Error()
-				}
-			}
+	if err != nil {
+		fmt.Println(err.Error())
+		if log.Logger != nil && log.Logger.DoPanic {
+			panic(err)
 		}
 	}
+}
+
+// creates emitter, appender can be also created without constructor
+func (ha *HekaAppender) init() error {
+	if ha.emitter != nil {
+		return nil
+	}
+
+	if len(ha.Addr) == 0 {
+		return fmt.Errorf("Missing remote host")
+	}
+
+	if ha.conn == nil {
+		ha.conn = NewPool(golog.Conf{"proto": ha.Proto, "addr": ha.Addr})
+	}
+
+	hostname := ha.Hostname
+	if hostname == "" {
+		hostname, _ = os.Hostname()
+	}
+
+	emitter := heka_emitter.NewProtobufEmitter(ha.conn, ha.EnvVersion, hostname, "")
+	if err := emitter.Configure(ha.cnf); err != nil {
+		return err
+	}
+
+	ha.emitter = emitter
+	return nil
+}
+
+// Sends batched messages and closes connection to Heka.
+func (ha *HekaAppender) Close() error {
+	ha.mu.Lock()
+	defer ha.mu.Unlock()
+
+	if ha.emitter != nil {
+		return ha.emitter.Close()
+	}
+	if ha.conn != nil {
+		return ha.conn.Close()
+	}
+	return nil
+}
+
+// Sends batched messages.
+func (ha *HekaAppender) Flush() error {
+	ha.mu.Lock()
+	defer ha.mu.Unlock()
 
-	ha.emitter.Emit(int32(log.Level), ha.Type, log.Message, nil)
+	if ha.emitter == nil {
+		return nil
+	}
+	return ha.emitter.Flush()
 }
 
+// Function for creating Heka appender. Besides "env_version",
+// "message_type" and "hostname" (detected automatically if not set) keys,
+// it accepts connection settings described in NewConn. "addr" can contain
+// several collectors, messages are sent to them according to "balance" key
+// described in NewPool.
+// Batching, handling of oversized messages and signing are configured
+// with keys described in heka_emitter.ProtobufEmitter.Configure.
 func Heka(cnf golog.Conf) *HekaAppender {
 	return &HekaAppender{
 		Addr:       cnf["addr"],
 		Proto:      cnf["proto"],
 		EnvVersion: cnf["env_version"],
 		Type:       cnf["message_type"],
+		Hostname:   cnf["hostname"],
+		conn:       NewPool(cnf),
+		cnf:        cnf,
 	}
 }
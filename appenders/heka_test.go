@@ -4,8 +4,12 @@ import (
 	"bytes"
 	"github.com/ildus/golog"
 	"github.com/ildus/golog/heka_emitter"
+	"github.com/ildus/golog/heka_emitter/hekatest"
+	"github.com/ildus/golog/logtest"
 	"github.com/stretchr/testify/assert"
+	"os"
 	"testing"
+	"time"
 )
 
 func TestHekaId(t *testing.T) {
@@ -13,33 +17,247 @@ func TestHekaId(t *testing.T) {
 	assert.Equal(t, "github.com/ildus/golog/appenders/heka", appender.Id())
 }
 
+// message emitted by newTestEmitter
+var testHekaMessage = []byte{
+	0xa, 0x10, 0xd1, 0xc7, 0xc7, 0x68, 0xb1, 0xbe, 0x4c, 0x70, 0x93, 0xa6, 0x9b,
+	0x52, 0x91, 0xd, 0x4b, 0xaa, 0x10, 0x80, 0xc0, 0xe1, 0xd8, 0xda, 0xfd, 0xbb,
+	0xba, 0x11, 0x1a, 0x4, 0x74, 0x65, 0x73, 0x74, 0x22, 0x11, 0x74, 0x65, 0x73,
+	0x74, 0x2d, 0x6a, 0x73, 0x6f, 0x6e, 0x2d, 0x65, 0x6d, 0x69, 0x74, 0x74, 0x65,
+	0x72, 0x28, 0x6, 0x32, 0x5, 0x48, 0x6f, 0x77, 0x64, 0x79, 0x3a, 0x1, 0x32,
+	0x40, 0xd2, 0x9, 0x4a, 0xb, 0x65, 0x78, 0x61, 0x6d, 0x70, 0x6c, 0x65, 0x2e,
+	0x63, 0x6f, 0x6d, 0x52, 0xa, 0xa, 0x1, 0x61, 0x10, 0x0, 0x1a, 0x0, 0x22,
+	0x1, 0x62, 0x52, 0xa, 0xa, 0x1, 0x63, 0x10, 0x0, 0x1a, 0x0, 0x22, 0x1, 0x64,
+	0x52, 0xa, 0xa, 0x1, 0x65, 0x10, 0x0, 0x1a, 0x0, 0x22, 0x1, 0x66,
+}
+
+// d1c7c768-b1be-4c70-93a6-9b52910d4baa
+var testHekaID = []byte{0xd1, 0xc7, 0xc7, 0x68, 0xb1, 0xbe, 0x4c, 0x70, 0x93,
+	0xa6, 0x9b, 0x52, 0x91, 0x0d, 0x4b, 0xaa}
+
+func newTestEmitter(buf *bytes.Buffer) *heka_emitter.ProtobufEmitter {
+	env := logtest.NewEnvironment()
+	env.ID = testHekaID
+
+	pe := heka_emitter.NewProtobufEmitter(buf, "2", "example.com", "test-json-emitter")
+	pe.Environment = env
+	return pe
+}
+
+func emitTestMessage(t *testing.T, pe *heka_emitter.ProtobufEmitter) {
+	var fields []*heka_emitter.Field
+	for _, kv := range [][2]string{{"c", "d"}, {"a", "b"}, {"e", "f"}} {
+		field, _ := heka_emitter.NewField(kv[0], kv[1], "")
+		fields = append(fields, field)
+	}
+
+	err := pe.Emit(int32(golog.INFO), "test", "Howdy", fields)
+	if err != nil {
+		t.Errorf("Error marshaling framed log message: %s", err)
+	}
+}
+
 func TestProtobufEmitter(t *testing.T) {
 	buf := new(bytes.Buffer)
-	pe := heka_emitter.NewProtobufEmitter(buf, "2", "example.com", "test-json-emitter")
-	pe.UseMockFuncs = true
-	expected := []byte{
+	pe := newTestEmitter(buf)
+	expected := append([]byte{
 		0x1e, 0x2,
 		// Header.
 		0x8, 0x75,
 		0x1f,
 		// Message.
-		0xa, 0x10, 0xd1, 0xc7, 0xc7, 0x68, 0xb1, 0xbe, 0x4c, 0x70, 0x93, 0xa6, 0x9b,
-		0x52, 0x91, 0xd, 0x4b, 0xaa, 0x10, 0x80, 0xc0, 0xe1, 0xd8, 0xda, 0xfd, 0xbb,
-		0xba, 0x11, 0x1a, 0x4, 0x74, 0x65, 0x73, 0x74, 0x22, 0x11, 0x74, 0x65, 0x73,
-		0x74, 0x2d, 0x6a, 0x73, 0x6f, 0x6e, 0x2d, 0x65, 0x6d, 0x69, 0x74, 0x74, 0x65,
-		0x72, 0x28, 0x6, 0x32, 0x5, 0x48, 0x6f, 0x77, 0x64, 0x79, 0x3a, 0x1, 0x32,
-		0x40, 0xd2, 0x9, 0x4a, 0xb, 0x65, 0x78, 0x61, 0x6d, 0x70, 0x6c, 0x65, 0x2e,
-		0x63, 0x6f, 0x6d, 0x52, 0xa, 0xa, 0x1, 0x61, 0x10, 0x0, 0x1a, 0x0, 0x22,
-		0x1, 0x62, 0x52, 0xa, 0xa, 0x1, 0x63, 0x10, 0x0, 0x1a, 0x0, 0x22, 0x1, 0x64,
-		0x52, 0xa, 0xa, 0x1, 0x65, 0x10, 0x0, 0x1a, 0x0, 0x22, 0x1, 0x66,
-	}
-	err := pe.Emit(int32(golog.INFO), "test", "Howdy",
-		map[string]string{"c": "d", "a": "b", "e": "f"})
-	if err != nil {
-		t.Errorf("Error marshaling framed log message: %s", err)
-	}
+	}, testHekaMessage...)
+
+	emitTestMessage(t, pe)
 	if !bytes.Equal(buf.Bytes(), expected) {
 		t.Errorf("Malformed framed log message: got %#v; want %#v",
 			buf.Bytes(), expected)
 	}
 }
+
+func TestProtobufEmitterHmac(t *testing.T) {
+	headers := map[string][]byte{
+		"md5": {
+			0x1e, 0x1f,
+			// Header.
+			0x8, 0x75, 0x18, 0x0, 0x22, 0x5, 0x67, 0x6f, 0x6c, 0x6f, 0x67, 0x28,
+			0x2, 0x32, 0x10, 0x4a, 0x27, 0x60, 0x23, 0x31, 0x85, 0x63, 0x17, 0xdf,
+			0x1c, 0x88, 0x1c, 0x7a, 0x2, 0x31, 0x46,
+			0x1f,
+		},
+		"sha1": {
+			0x1e, 0x23,
+			// Header.
+			0x8, 0x75, 0x18, 0x1, 0x22, 0x5, 0x67, 0x6f, 0x6c, 0x6f, 0x67, 0x28,
+			0x2, 0x32, 0x14, 0x5e, 0x95, 0xbb, 0xf1, 0xe9, 0x78, 0x1c, 0xc4, 0x2c,
+			0x3d, 0x3c, 0x97, 0x2c, 0xb3, 0x74, 0x17, 0x2d, 0x3f, 0xe4, 0x1a,
+			0x1f,
+		},
+	}
+
+	for hash, header := range headers {
+		buf := new(bytes.Buffer)
+		pe := newTestEmitter(buf)
+		err := pe.ConfigureHmac(map[string]string{
+			"hmac_signer":      "golog",
+			"hmac_key_version": "2",
+			"hmac_key":         "secret",
+			"hmac_hash":        hash,
+		})
+		assert.Nil(t, err)
+
+		// second message checks that pooled header is reused correctly
+		emitTestMessage(t, pe)
+		emitTestMessage(t, pe)
+
+		frame := append(append([]byte{}, header...), testHekaMessage...)
+		expected := append(append([]byte{}, frame...), frame...)
+		if !bytes.Equal(buf.Bytes(), expected) {
+			t.Errorf("Malformed %s signed log message: got %#v; want %#v",
+				hash, buf.Bytes(), expected)
+		}
+	}
+
+	// unsigned messages should not carry hmac from pooled header
+	buf := new(bytes.Buffer)
+	emitTestMessage(t, newTestEmitter(buf))
+	assert.Equal(t, []byte{0x1e, 0x2, 0x8, 0x75, 0x1f}, buf.Bytes()[:5])
+}
+
+func TestProtobufEmitterHmacConf(t *testing.T) {
+	pe := newTestEmitter(new(bytes.Buffer))
+	assert.Nil(t, pe.ConfigureHmac(map[string]string{}))
+	assert.Equal(t, "", pe.HmacKey)
+
+	assert.NotNil(t, pe.ConfigureHmac(map[string]string{"hmac_key": "secret"}))
+	assert.NotNil(t, pe.ConfigureHmac(map[string]string{
+		"hmac_key": "secret", "hmac_signer": "golog", "hmac_key_version": "-1",
+	}))
+	assert.NotNil(t, pe.ConfigureHmac(map[string]string{
+		"hmac_key": "secret", "hmac_signer": "golog", "hmac_hash": "sha256",
+	}))
+}
+
+func TestHekaAppend(t *testing.T) {
+	receiver, err := hekatest.NewReceiver("tcp")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer receiver.Close()
+
+	appender := Heka(golog.Conf{
+		"addr":         receiver.Addr,
+		"message_type": "test",
+		"hostname":     "example.com",
+	})
+	defer appender.Close()
+
+	// both loggers share the same appender
+	first := &golog.Logger{Name: "first", Level: golog.DEBUG}
+	first.Enable(appender)
+	second := &golog.Logger{Name: "second", Level: golog.DEBUG}
+	second.Enable(appender)
+
+	first.Info("Howdy", map[string]string{"request": "r-42"})
+	second.Error("Bye")
+
+	appender.Append(golog.Log{
+		Time:    time.Unix(1257894000, 0),
+		Message: "direct",
+		Level:   golog.WARNING,
+		Pid:     4321,
+		Logger:  second,
+	})
+
+	messages, err := receiver.Wait(3, 5*time.Second)
+	assert.Nil(t, err)
+
+	assert.Equal(t, "Howdy", messages[0].GetPayload())
+	assert.Equal(t, "first", messages[0].GetLogger())
+	assert.Equal(t, "test", messages[0].GetType())
+	assert.Equal(t, "example.com", messages[0].GetHostname())
+	assert.Equal(t, int32(golog.INFO), messages[0].GetSeverity())
+	assert.Equal(t, int32(os.Getpid()), messages[0].GetPid())
+	assert.Equal(t, "request", messages[0].Fields[0].GetName())
+	assert.Equal(t, []string{"r-42"}, messages[0].Fields[0].GetValueString())
+
+	assert.Equal(t, "Bye", messages[1].GetPayload())
+	assert.Equal(t, "second", messages[1].GetLogger())
+	assert.Equal(t, int32(golog.ERROR), messages[1].GetSeverity())
+	assert.True(t, messages[1].GetTimestamp() >= messages[0].GetTimestamp())
+
+	assert.Equal(t, "direct", messages[2].GetPayload())
+	assert.Equal(t, int64(1257894000000000000), messages[2].GetTimestamp())
+	assert.Equal(t, int32(4321), messages[2].GetPid())
+	assert.Equal(t, int32(golog.WARNING), messages[2].GetSeverity())
+}
+
+func TestHekaHostname(t *testing.T) {
+	receiver, err := hekatest.NewReceiver("udp")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer receiver.Close()
+
+	appender := Heka(golog.Conf{"proto": "udp", "addr": receiver.Addr})
+	defer appender.Close()
+
+	appender.Append(golog.Log{Message: "some message"})
+
+	messages, err := receiver.Wait(1, 5*time.Second)
+	assert.Nil(t, err)
+
+	hostname, _ := os.Hostname()
+	assert.Equal(t, hostname, messages[0].GetHostname())
+}
+
+func TestHekaGolden(t *testing.T) {
+	receiver, err := hekatest.NewReceiver("tcp")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer receiver.Close()
+
+	appender := Heka(golog.Conf{"addr": receiver.Addr, "hostname": "example.com", "env_version": "1"})
+	defer appender.Close()
+
+	logGolden(goldenLogger(appender))
+
+	messages, err := receiver.Wait(1, 5*time.Second)
+	assert.Nil(t, err)
+	assert.Equal(t, `uuid:"\001$\340S5\200p\000\200\000\000\000\000\000\000\001" `+
+		`timestamp:1257894000000000000 type:"" logger:"golden" severity:4 payload:"card declined" `+
+		`env_version:"1" pid:1234 hostname:"example.com" `+
+		`fields:<name:"amount" value_type:INTEGER representation:"" value_integer:10 > `,
+		messages[0].String())
+}
+
+func TestNewField(t *testing.T) {
+	field, err := heka_emitter.NewField("count", 42, "count")
+	assert.Nil(t, err)
+	assert.Equal(t, heka_emitter.Field_INTEGER, field.GetValueType())
+	assert.Equal(t, "count", field.GetRepresentation())
+	assert.Equal(t, []int64{42}, field.GetValueInteger())
+
+	field, err = heka_emitter.NewField("ratio", 0.5, "")
+	assert.Nil(t, err)
+	assert.Equal(t, heka_emitter.Field_DOUBLE, field.GetValueType())
+	assert.Equal(t, []float64{0.5}, field.GetValueDouble())
+
+	field, err = heka_emitter.NewField("ok", true, "")
+	assert.Nil(t, err)
+	assert.Equal(t, heka_emitter.Field_BOOL, field.GetValueType())
+	assert.Equal(t, []bool{true}, field.GetValueBool())
+
+	field, err = heka_emitter.NewField("raw", []byte{1, 2}, "")
+	assert.Nil(t, err)
+	assert.Equal(t, heka_emitter.Field_BYTES, field.GetValueType())
+	assert.Equal(t, [][]byte{{1, 2}}, field.GetValueBytes())
+
+	field, err = heka_emitter.NewField("name", "golog", "")
+	assert.Nil(t, err)
+	assert.Equal(t, heka_emitter.Field_STRING, field.GetValueType())
+	assert.Equal(t, []string{"golog"}, field.GetValueString())
+
+	_, err = heka_emitter.NewField("complex", complex(1, 2), "")
+	assert.NotNil(t, err)
+}
@@ -0,0 +1,214 @@
+package appenders
+
+import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"net"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"syscall"
+
+	"github.com/ildus/golog"
+	"github.com/ildus/golog/id"
+)
+
+// default location of journald native protocol socket
+const journaldSocket = "/run/systemd/journal/socket"
+
+// JournaldAppender writes logs to systemd journal using native protocol.
+// Level is sent as PRIORITY, logger name as SYSLOG_IDENTIFIER, log ID
+// as GOLOG_ID, and maps and errors from log data become upper-cased
+// journal fields. Data fields which would collide with these ones, or with
+// CODE_* fields, are prefixed with DATA_.
+type JournaldAppender struct {
+	Socket string
+
+	mu   sync.Mutex
+	conn *net.UnixConn
+	addr *net.UnixAddr
+}
+
+// github.com/ildus/golog/appenders/journald
+func (ja *JournaldAppender) Id() string {
+	return "github.com/ildus/golog/appenders/journald"
+}
+
+func (ja *JournaldAppender) Append(log golog.Log) {
+	if err := ja.send(journaldEntry(log)); err != nil {
+		fmt.Println(err.Error())
+		if log.Logger != nil && log.Logger.DoPanic {
+			panic(err)
+		}
+	}
+}
+
+// Closes connection to journald socket. It will be reopened on next log.
+func (ja *JournaldAppender) Close() error {
+	ja.mu.Lock()
+	defer ja.mu.Unlock()
+
+	if ja.conn == nil {
+		return nil
+	}
+
+	err := ja.conn.Close()
+	ja.conn = nil
+	return err
+}
+
+func (ja *JournaldAppender) send(entry []byte) error {
+	ja.mu.Lock()
+	defer ja.mu.Unlock()
+
+	// socket is left unconnected, connected datagram sockets
+	// can't be used for sending file descriptors
+	if ja.conn == nil {
+		conn, err := net.ListenUnixgram("unixgram", &net.UnixAddr{Name: "", Net: "unixgram"})
+		if err != nil {
+			return fmt.Errorf("Error opening socket for journald: %s", err)
+		}
+		ja.conn = conn
+		ja.addr = &net.UnixAddr{Name: ja.Socket, Net: "unixgram"}
+	}
+
+	_, err := ja.conn.WriteToUnix(entry, ja.addr)
+	if err == nil {
+		return nil
+	}
+
+	// entry doesn't fit into one datagram, pass it through memfd
+	if errors.Is(err, syscall.EMSGSIZE) || errors.Is(err, syscall.ENOBUFS) {
+		err = sendJournaldFd(ja.conn, ja.addr, entry)
+	}
+
+	if err != nil {
+		return fmt.Errorf("Error sending log to journald: %s", err)
+	}
+	return nil
+}
+
+// journaldEntry serializes log into journald native protocol format.
+func journaldEntry(log golog.Log) []byte {
+	var buf []byte
+
+	buf = appendJournaldField(buf, "MESSAGE", log.Message)
+	buf = appendJournaldField(buf, "PRIORITY", strconv.Itoa(int(log.Level)))
+
+	if log.Logger != nil {
+		buf = appendJournaldField(buf, "SYSLOG_IDENTIFIER", log.Logger.Name)
+	}
+
+	if logID, err := id.Encode(log.ID); err == nil {
+		buf = appendJournaldField(buf, "GOLOG_ID", logID)
+	}
+
+	if log.Pid != 0 {
+		buf = appendJournaldField(buf, "SYSLOG_PID", strconv.Itoa(log.Pid))
+	}
+
+	if file, line, fn, ok := golog.Caller(); ok {
+		buf = appendJournaldField(buf, "CODE_FILE", file)
+		buf = appendJournaldField(buf, "CODE_LINE", strconv.Itoa(line))
+		buf = appendJournaldField(buf, "CODE_FUNC", fn)
+	}
+
+	fields := map[string]string{}
+	for _, item := range log.Data {
+		switch item := item.(type) {
+		case map[string]string:
+			for key, val := range item {
+				fields[key] = val
+			}
+		case map[string]interface{}:
+			for key, val := range item {
+				fields[key] = fmt.Sprint(val)
+			}
+		case error:
+			fields["error"] = item.Error()
+		}
+	}
+
+	names := make([]string, 0, len(fields))
+	for key := range fields {
+		names = append(names, key)
+	}
+	sort.Strings(names)
+
+	for _, key := range names {
+		name := journaldFieldName(key)
+		if name == "" {
+			continue
+		}
+		if journaldReserved(name) {
+			name = "DATA_" + name
+		}
+		buf = appendJournaldField(buf, name, fields[key])
+	}
+
+	return buf
+}
+
+// Values without newlines are written as NAME=value, others are written
+// as name, newline, little endian 64 bit length and raw value.
+func appendJournaldField(buf []byte, name, value string) []byte {
+	buf = append(buf, name...)
+	if strings.IndexByte(value, '\n') >= 0 {
+		var size [8]byte
+		binary.LittleEndian.PutUint64(size[:], uint64(len(value)))
+		buf = append(buf, '\n')
+		buf = append(buf, size[:]...)
+	} else {
+		buf = append(buf, '=')
+	}
+	buf = append(buf, value...)
+	return append(buf, '\n')
+}
+
+// Converts key to valid journal field name. Names can contain only
+// upper-case letters, digits and underscores, cannot start with digit or
+// underscore (those are reserved for trusted fields) and are limited to
+// 64 characters. Empty string is returned if key cannot be converted.
+func journaldFieldName(key string) string {
+	name := []byte(strings.ToUpper(key))
+	for i, c := range name {
+		if (c < 'A' || c > 'Z') && (c < '0' || c > '9') {
+			name[i] = '_'
+		}
+	}
+
+	result := strings.TrimLeft(string(name), "_")
+	if len(result) > 64 {
+		result = result[:64]
+	}
+	if result == "" || (result[0] >= '0' && result[0] <= '9') {
+		return ""
+	}
+	return result
+}
+
+// Reports if name is used by fields which appender sets itself,
+// data fields with such names are prefixed with DATA_.
+func journaldReserved(name string) bool {
+	switch name {
+	case "MESSAGE", "PRIORITY", "SYSLOG_IDENTIFIER", "SYSLOG_PID", "GOLOG_ID":
+		return true
+	}
+	return strings.HasPrefix(name, "CODE_")
+}
+
+// Function for creating journald appender.
+// Socket path can be changed using "socket" key, by default
+// /run/systemd/journal/socket is used.
+func Journald(cnf golog.Conf) *JournaldAppender {
+	socket := cnf["socket"]
+	if socket == "" {
+		socket = journaldSocket
+	}
+
+	return &JournaldAppender{
+		Socket: socket,
+	}
+}
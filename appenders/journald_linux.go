@@ -0,0 +1,32 @@
+package appenders
+
+import (
+	"net"
+	"os"
+
+	"golang.org/x/sys/unix"
+)
+
+// Passes entry to journald through sealed memfd, journald accepts
+// it when entry is too big to be sent as one datagram.
+func sendJournaldFd(conn *net.UnixConn, addr *net.UnixAddr, entry []byte) error {
+	fd, err := unix.MemfdCreate("golog-journald", unix.MFD_CLOEXEC|unix.MFD_ALLOW_SEALING)
+	if err != nil {
+		return err
+	}
+
+	f := os.NewFile(uintptr(fd), "golog-journald")
+	defer f.Close()
+
+	if _, err = f.Write(entry); err != nil {
+		return err
+	}
+
+	seals := unix.F_SEAL_SHRINK | unix.F_SEAL_GROW | unix.F_SEAL_WRITE | unix.F_SEAL_SEAL
+	if _, err = unix.FcntlInt(f.Fd(), unix.F_ADD_SEALS, seals); err != nil {
+		return err
+	}
+
+	_, _, err = conn.WriteMsgUnix(nil, unix.UnixRights(int(f.Fd())), addr)
+	return err
+}
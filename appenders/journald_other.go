@@ -0,0 +1,14 @@
+//go:build !linux
+// +build !linux
+
+package appenders
+
+import (
+	"errors"
+	"net"
+)
+
+// memfd is available only on linux
+func sendJournaldFd(conn *net.UnixConn, addr *net.UnixAddr, entry []byte) error {
+	return errors.New("log entry is too big for journald datagram")
+}
@@ -0,0 +1,212 @@
+//go:build linux
+// +build linux
+
+package appenders
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"io"
+	"io/ioutil"
+	"net"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"syscall"
+	"testing"
+
+	"github.com/ildus/golog"
+	"github.com/ildus/golog/id"
+	"github.com/stretchr/testify/assert"
+)
+
+func journaldListener(t *testing.T) (*net.UnixConn, string, func()) {
+	dir, err := ioutil.TempDir("", "golog-journald")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	path := filepath.Join(dir, "socket")
+	conn, err := net.ListenUnixgram("unixgram", &net.UnixAddr{Name: path, Net: "unixgram"})
+	if err != nil {
+		os.RemoveAll(dir)
+		t.Fatal(err)
+	}
+
+	return conn, path, func() {
+		conn.Close()
+		os.RemoveAll(dir)
+	}
+}
+
+// parses journald native protocol entry to map
+func parseJournaldEntry(t *testing.T, entry []byte) map[string]string {
+	fields := map[string]string{}
+	for len(entry) > 0 {
+		end := bytes.IndexByte(entry, '\n')
+		if end < 0 {
+			t.Fatalf("Unterminated journald field: %q", entry)
+		}
+
+		line := string(entry[:end])
+		entry = entry[end+1:]
+		if eq := strings.IndexByte(line, '='); eq >= 0 {
+			fields[line[:eq]] = line[eq+1:]
+			continue
+		}
+
+		size := int(binary.LittleEndian.Uint64(entry[:8]))
+		fields[line] = string(entry[8 : 8+size])
+		entry = entry[8+size+1:]
+	}
+	return fields
+}
+
+func TestJournaldId(t *testing.T) {
+	appender := Journald(golog.Conf{})
+	assert.Equal(t, "github.com/ildus/golog/appenders/journald", appender.Id())
+	assert.Equal(t, "/run/systemd/journal/socket", appender.Socket)
+}
+
+func TestJournaldAppend(t *testing.T) {
+	conn, path, cleanup := journaldListener(t)
+	defer cleanup()
+
+	appender := Journald(golog.Conf{"socket": path})
+	defer appender.Close()
+
+	logger := &golog.Logger{Name: "journald", Level: golog.DEBUG}
+	logger.Enable(appender)
+	logger.Warn("first line\nsecond line",
+		map[string]string{"request-id": "42", "_hidden": "x", "1st": "skipped"},
+		errors.New("some error"))
+
+	buf := make([]byte, 4096)
+	n, err := conn.Read(buf)
+	assert.Nil(t, err)
+
+	fields := parseJournaldEntry(t, buf[:n])
+	assert.Equal(t, "first line\nsecond line", fields["MESSAGE"])
+	assert.Equal(t, "4", fields["PRIORITY"])
+	assert.Equal(t, "journald", fields["SYSLOG_IDENTIFIER"])
+	assert.Equal(t, "42", fields["REQUEST_ID"])
+	assert.Equal(t, "x", fields["HIDDEN"])
+	assert.Equal(t, "some error", fields["ERROR"])
+	assert.True(t, strings.HasSuffix(fields["CODE_FILE"], "journald_test.go"))
+	assert.Equal(t, "github.com/ildus/golog/appenders.TestJournaldAppend", fields["CODE_FUNC"])
+	_, err = strconv.Atoi(fields["CODE_LINE"])
+	assert.Nil(t, err)
+	assert.NotEmpty(t, fields["SYSLOG_PID"])
+	assert.True(t, id.Valid(fields["GOLOG_ID"]))
+	assert.Len(t, fields, 11)
+}
+
+func TestJournaldReservedFields(t *testing.T) {
+	entry := journaldEntry(golog.Log{
+		Message: "real",
+		Level:   golog.INFO,
+		Data: []interface{}{map[string]interface{}{
+			"message": "fake", "priority": 0, "code_file": "x.go", "syslog_pid": 1, "user": "bob",
+		}},
+	})
+
+	// fields set by appender aren't repeated
+	lines := strings.Split(string(entry), "\n")
+	assert.NotContains(t, lines, "MESSAGE=fake")
+	assert.NotContains(t, lines, "PRIORITY=0")
+	assert.Equal(t, map[string]string{
+		"MESSAGE":         "real",
+		"PRIORITY":        "6",
+		"DATA_MESSAGE":    "fake",
+		"DATA_PRIORITY":   "0",
+		"DATA_CODE_FILE":  "x.go",
+		"DATA_SYSLOG_PID": "1",
+		"USER":            "bob",
+	}, parseJournaldEntry(t, entry))
+}
+
+func TestJournaldGolden(t *testing.T) {
+	conn, path, cleanup := journaldListener(t)
+	defer cleanup()
+
+	appender := Journald(golog.Conf{"socket": path})
+	defer appender.Close()
+
+	logGolden(goldenLogger(appender))
+
+	buf := make([]byte, 4096)
+	n, err := conn.Read(buf)
+	assert.Nil(t, err)
+
+	// location of caller depends on build
+	fields := parseJournaldEntry(t, buf[:n])
+	for name := range fields {
+		if strings.HasPrefix(name, "CODE_") {
+			delete(fields, name)
+		}
+	}
+	assert.Equal(t, map[string]string{
+		"MESSAGE":           "card declined",
+		"PRIORITY":          "4",
+		"SYSLOG_IDENTIFIER": "golden",
+		"GOLOG_ID":          "0124e053-3580-7000-8000-000000000001",
+		"SYSLOG_PID":        "1234",
+		"AMOUNT":            "10",
+	}, fields)
+}
+
+func TestJournaldAppendWithoutLogger(t *testing.T) {
+	conn, path, cleanup := journaldListener(t)
+	defer cleanup()
+
+	appender := Journald(golog.Conf{"socket": path})
+	defer appender.Close()
+
+	appender.Append(golog.Log{Message: "some message", Level: golog.ERROR, Pid: 10})
+
+	buf := make([]byte, 4096)
+	n, err := conn.Read(buf)
+	assert.Nil(t, err)
+	assert.Equal(t, map[string]string{
+		"MESSAGE":    "some message",
+		"PRIORITY":   "3",
+		"SYSLOG_PID": "10",
+	}, parseJournaldEntry(t, buf[:n]))
+}
+
+func TestJournaldAppendLarge(t *testing.T) {
+	conn, path, cleanup := journaldListener(t)
+	defer cleanup()
+
+	appender := Journald(golog.Conf{"socket": path})
+	defer appender.Close()
+
+	message := strings.Repeat("a", 4<<20)
+	appender.Append(golog.Log{Message: message, Level: golog.INFO})
+
+	oob := make([]byte, syscall.CmsgSpace(4))
+	_, oobn, _, _, err := conn.ReadMsgUnix(nil, oob)
+	assert.Nil(t, err)
+
+	msgs, err := syscall.ParseSocketControlMessage(oob[:oobn])
+	assert.Nil(t, err)
+	assert.Len(t, msgs, 1)
+
+	fds, err := syscall.ParseUnixRights(&msgs[0])
+	assert.Nil(t, err)
+	assert.Len(t, fds, 1)
+
+	f := os.NewFile(uintptr(fds[0]), "memfd")
+	defer f.Close()
+
+	// descriptor shares offset with the one used for writing
+	entry, err := ioutil.ReadAll(io.NewSectionReader(f, 0, 8<<20))
+	assert.Nil(t, err)
+
+	fields := parseJournaldEntry(t, entry)
+	assert.Len(t, fields["MESSAGE"], len(message))
+	assert.True(t, fields["MESSAGE"] == message)
+	assert.Equal(t, "6", fields["PRIORITY"])
+}
@@ -1,14 +1,33 @@
 package appenders
 
 import (
+	"fmt"
+	"strconv"
+	"sync"
+	"time"
+
 	"github.com/ildus/golog"
 	"gopkg.in/mgo.v2"
+	"gopkg.in/mgo.v2/bson"
 )
 
+const defaultMongoTimeout = 10 * time.Second
+
+// MongoAppender inserts logs into Mongo collection. If BatchSize is set,
+// logs are gathered and inserted together when batch reaches BatchSize
+// logs or FlushInterval passes after first log in batch.
 type MongoAppender struct {
+	BatchSize     int
+	FlushInterval time.Duration
+
 	session    *mgo.Session
 	db         string
 	collection string
+
+	mu       sync.Mutex
+	batch    []interface{}
+	timer    *time.Timer
+	flushErr error
 }
 
 // github.com/ildus/golog/appenders/mongo
@@ -17,28 +36,301 @@ func (ma *MongoAppender) Id() string {
 }
 
 func (ma *MongoAppender) Append(log golog.Log) {
-	ma.session.Copy()
-	defer ma.session.Close()
+	if err := ma.add(log); err != nil {
+		fmt.Println(err.Error())
+		if log.Logger != nil && log.Logger.DoPanic {
+			panic(err)
+		}
+	}
+}
 
-	c := ma.session.DB(ma.db).C(ma.collection)
-	c.Insert(log)
+func (ma *MongoAppender) add(log golog.Log) error {
+	ma.mu.Lock()
+	defer ma.mu.Unlock()
+
+	// errors of flushes made by timer are reported with next log
+	err := ma.flushErr
+	ma.flushErr = nil
+
+	ma.batch = append(ma.batch, log)
+	if len(ma.batch) < ma.BatchSize {
+		ma.startTimer()
+		return err
+	}
+
+	if flushErr := ma.flush(); err == nil {
+		err = flushErr
+	}
+	return err
 }
 
-func Mongo(cnf golog.Conf) *MongoAppender {
-	sess, err := mgo.DialWithInfo(&mgo.DialInfo{
+func (ma *MongoAppender) startTimer() {
+	if ma.timer != nil {
+		return
+	}
+
+	interval := ma.FlushInterval
+	if interval <= 0 {
+		interval = time.Second
+	}
+	ma.timer = time.AfterFunc(interval, ma.flushTimer)
+}
+
+// Flush inserts batched logs.
+func (ma *MongoAppender) Flush() error {
+	ma.mu.Lock()
+	defer ma.mu.Unlock()
+
+	return ma.flush()
+}
+
+func (ma *MongoAppender) flushTimer() {
+	ma.mu.Lock()
+	defer ma.mu.Unlock()
+
+	ma.timer = nil
+	if err := ma.flush(); err != nil {
+		ma.flushErr = err
+	}
+}
+
+// every flush uses its own copy of session, so slow inserts
+// don't block other users of connection pool
+func (ma *MongoAppender) flush() error {
+	if ma.timer != nil {
+		ma.timer.Stop()
+		ma.timer = nil
+	}
+
+	if len(ma.batch) == 0 {
+		return nil
+	}
+
+	session := ma.session.Copy()
+	defer session.Close()
+
+	bulk := session.DB(ma.db).C(ma.collection).Bulk()
+	bulk.Unordered()
+	bulk.Insert(ma.batch...)
+
+	count := len(ma.batch)
+	ma.batch = ma.batch[:0]
+
+	// logs are stored with their IDs, so logs which are already
+	// inserted (e.g. batch is retried) are just skipped
+	if _, err := bulk.Run(); err != nil && !mgo.IsDup(err) {
+		return fmt.Errorf("Error inserting %d logs into Mongo: %s", count, err)
+	}
+	return nil
+}
+
+// Close inserts batched logs and closes session.
+func (ma *MongoAppender) Close() error {
+	ma.mu.Lock()
+	defer ma.mu.Unlock()
+
+	err := ma.flush()
+	ma.session.Close()
+	return err
+}
+
+// Function for creating Mongo appender. Supported keys:
+//
+//	host           - address of Mongo server
+//	db             - database where logs are saved
+//	collection     - collection where logs are saved
+//	username       - database username (if exists)
+//	password       - database password (if exists)
+//	dial_timeout   - timeout for connecting to server (e.g. "5s"), default is 10s
+//	batch_size     - logs are inserted when batch reaches this number of logs
+//	flush_interval - maximum delay of batched logs (e.g. "100ms"), default is 1s
+//	w              - number of servers which acknowledge writes or mode
+//	                 (e.g. "majority"), "0" turns off acknowledgement
+//	wtimeout       - time limit for acknowledgement (e.g. "5s")
+//	journal        - "true" to wait until writes are written to journal
+//	fsync          - "true" to wait until writes are synced to disk
+//	capped_size    - if set, new collection is created as capped with this
+//	                 maximum size in bytes
+//	capped_max     - maximum number of logs in capped collection
+//	ttl            - logs older than ttl (e.g. "720h") are removed by server,
+//	                 it can't be used with capped collection
+//
+// Indexes on "level", "logger.name" and "time" are created if they don't exist.
+// Writes are acknowledged by one server by default. Failed inserts are
+// printed and cause panic if logger has DoPanic set, failures of inserts
+// made by timer are reported with next log.
+func Mongo(cnf golog.Conf) (*MongoAppender, error) {
+	ma := &MongoAppender{
+		db:         cnf["db"],
+		collection: cnf["collection"],
+	}
+
+	if val := cnf["batch_size"]; val != "" {
+		size, err := strconv.Atoi(val)
+		if err != nil {
+			return nil, fmt.Errorf("Invalid batch_size %q: %s", val, err)
+		}
+		ma.BatchSize = size
+	}
+
+	interval, err := confDuration(cnf, "flush_interval")
+	if err != nil {
+		return nil, err
+	}
+	ma.FlushInterval = interval
+
+	timeout, err := confDuration(cnf, "dial_timeout")
+	if err != nil {
+		return nil, err
+	}
+	if timeout == 0 {
+		timeout = defaultMongoTimeout
+	}
+
+	safe, err := writeConcern(cnf)
+	if err != nil {
+		return nil, err
+	}
+
+	info, ttl, err := retention(cnf)
+	if err != nil {
+		return nil, err
+	}
+
+	ma.session, err = mgo.DialWithInfo(&mgo.DialInfo{
 		Database: cnf["db"],
 		Username: cnf["username"],
 		Password: cnf["password"],
 		Addrs:    []string{cnf["host"]},
+		Timeout:  timeout,
 	})
+	if err != nil {
+		return nil, fmt.Errorf("Error connecting to Mongo %q: %s", cnf["host"], err)
+	}
+
+	ma.session.SetSafe(safe)
+
+	if err = ma.ensureCollection(info, ttl); err != nil {
+		ma.session.Close()
+		return nil, err
+	}
+	return ma, nil
+}
+
+// ensureCollection creates collection and indexes, existing ones are kept
+func (ma *MongoAppender) ensureCollection(info *mgo.CollectionInfo, ttl time.Duration) error {
+	c := ma.session.DB(ma.db).C(ma.collection)
+
+	if info != nil {
+		if err := c.Create(info); err != nil && !isMongoError(err, mongoNamespaceExists) {
+			return fmt.Errorf("Error creating Mongo collection %q: %s", ma.collection, err)
+		}
+	}
+
+	for _, key := range []string{"level", "logger.name"} {
+		if err := c.EnsureIndex(mgo.Index{Key: []string{key}, Background: true}); err != nil {
+			return fmt.Errorf("Error creating Mongo index on %q: %s", key, err)
+		}
+	}
 
+	err := c.EnsureIndex(mgo.Index{Key: []string{"time"}, Background: true, ExpireAfter: ttl})
+	if isMongoError(err, mongoIndexOptionsConflict) {
+		// index exists with other options, only TTL can be changed
+		if ttl == 0 {
+			return nil
+		}
+		err = ma.session.DB(ma.db).Run(bson.D{
+			{Name: "collMod", Value: ma.collection},
+			{Name: "index", Value: bson.M{
+				"keyPattern":         bson.M{"time": 1},
+				"expireAfterSeconds": int(ttl / time.Second),
+			}},
+		}, nil)
+	}
 	if err != nil {
-		panic(err)
+		return fmt.Errorf("Error creating Mongo index on \"time\": %s", err)
 	}
+	return nil
+}
 
-	return &MongoAppender{
-		db:         cnf["db"],
-		collection: cnf["collection"],
-		session:    sess,
+// Mongo error codes
+const (
+	mongoNamespaceExists      = 48
+	mongoIndexOptionsConflict = 85
+)
+
+func isMongoError(err error, code int) bool {
+	switch err := err.(type) {
+	case *mgo.QueryError:
+		return err.Code == code
+	case *mgo.LastError:
+		return err.Code == code
+	}
+	return false
+}
+
+// retention reads options of capped collection and TTL of logs
+func retention(cnf golog.Conf) (*mgo.CollectionInfo, time.Duration, error) {
+	ttl, err := confDuration(cnf, "ttl")
+	if err != nil {
+		return nil, 0, err
+	}
+	if ttl != 0 && ttl < time.Second {
+		return nil, 0, fmt.Errorf("Invalid ttl %q: should be at least 1s", cnf["ttl"])
+	}
+
+	if cnf["capped_size"] == "" {
+		if cnf["capped_max"] != "" {
+			return nil, 0, fmt.Errorf("capped_max requires capped_size")
+		}
+		return nil, ttl, nil
+	}
+
+	if ttl != 0 {
+		return nil, 0, fmt.Errorf("ttl can't be used with capped collection")
+	}
+
+	info := &mgo.CollectionInfo{Capped: true}
+	if info.MaxBytes, err = strconv.Atoi(cnf["capped_size"]); err != nil || info.MaxBytes <= 0 {
+		return nil, 0, fmt.Errorf("Invalid capped_size %q", cnf["capped_size"])
 	}
+
+	if val := cnf["capped_max"]; val != "" {
+		if info.MaxDocs, err = strconv.Atoi(val); err != nil || info.MaxDocs <= 0 {
+			return nil, 0, fmt.Errorf("Invalid capped_max %q", val)
+		}
+	}
+
+	return info, 0, nil
+}
+
+// writeConcern reads session safety mode from configuration,
+// nil means that writes are not acknowledged
+func writeConcern(cnf golog.Conf) (*mgo.Safe, error) {
+	safe := &mgo.Safe{
+		J:     cnf["journal"] == "true",
+		FSync: cnf["fsync"] == "true",
+	}
+
+	timeout, err := confDuration(cnf, "wtimeout")
+	if err != nil {
+		return nil, err
+	}
+	safe.WTimeout = int(timeout / time.Millisecond)
+
+	if val := cnf["w"]; val != "" {
+		w, err := strconv.Atoi(val)
+		switch {
+		case err != nil:
+			safe.WMode = val
+		case w < 0:
+			return nil, fmt.Errorf("Invalid w %q", val)
+		case w == 0 && !safe.J && !safe.FSync:
+			return nil, nil
+		default:
+			safe.W = w
+		}
+	}
+
+	return safe, nil
 }
@@ -2,17 +2,60 @@ package appenders
 
 import (
 	"github.com/ildus/golog"
+	"github.com/ildus/golog/id"
 	"github.com/stretchr/testify/assert"
 	"gopkg.in/mgo.v2"
 	"gopkg.in/mgo.v2/bson"
 	"testing"
+	"time"
 )
 
 func TestMongoId(t *testing.T) {
-	appender := Mongo(golog.Conf{})
+	appender := &MongoAppender{}
 	assert.Equal(t, "github.com/ildus/golog/appenders/mongo", appender.Id())
 }
 
+func TestMongoDialError(t *testing.T) {
+	appender, err := Mongo(golog.Conf{
+		"host":         unusedAddr(t),
+		"dial_timeout": "100ms",
+	})
+	assert.Nil(t, appender)
+	assert.NotNil(t, err)
+
+	_, err = Mongo(golog.Conf{"batch_size": "many"})
+	assert.NotNil(t, err)
+}
+
+func TestWriteConcern(t *testing.T) {
+	safe, err := writeConcern(golog.Conf{})
+	assert.Nil(t, err)
+	assert.Equal(t, &mgo.Safe{}, safe)
+
+	safe, err = writeConcern(golog.Conf{
+		"w":        "majority",
+		"wtimeout": "5s",
+		"journal":  "true",
+	})
+	assert.Nil(t, err)
+	assert.Equal(t, &mgo.Safe{WMode: "majority", WTimeout: 5000, J: true}, safe)
+
+	safe, err = writeConcern(golog.Conf{"w": "2", "fsync": "true"})
+	assert.Nil(t, err)
+	assert.Equal(t, &mgo.Safe{W: 2, FSync: true}, safe)
+
+	// writes are not acknowledged
+	safe, err = writeConcern(golog.Conf{"w": "0"})
+	assert.Nil(t, err)
+	assert.Nil(t, safe)
+
+	_, err = writeConcern(golog.Conf{"w": "-1"})
+	assert.NotNil(t, err)
+
+	_, err = writeConcern(golog.Conf{"wtimeout": "later"})
+	assert.NotNil(t, err)
+}
+
 func TestMongoAppend(t *testing.T) {
 	db := "test"
 	coll := "logs"
@@ -44,16 +87,23 @@ func TestMongoAppend(t *testing.T) {
 	assert.Exactly(t, 0, count)
 
 	log = golog.Log{
+		Time:    time.Date(2009, 11, 10, 23, 0, 0, 0, time.UTC),
 		Message: logtext,
-		// todo: add other
+		Level:   golog.ERROR,
+		Logger:  &golog.Logger{Name: "mongo"},
+		Data:    []interface{}{map[string]int{"size": 10}},
 	}
 
-	appender := Mongo(golog.Conf{
+	appender, err := Mongo(golog.Conf{
 		"host":       "127.0.0.1:27017",
 		"db":         db,
 		"collection": coll,
 	})
+	assert.Nil(t, err)
+	defer appender.Close()
 
+	appender.Append(log)
+	// session is still usable after first log
 	appender.Append(log)
 
 	// check if new log is sucesufully added
@@ -62,5 +112,210 @@ func TestMongoAppend(t *testing.T) {
 		"message": logtext,
 	}).Count()
 	assert.Nil(t, err)
-	assert.Exactly(t, 1, count)
+	assert.Exactly(t, 2, count)
+
+	// log is read back
+	stored := golog.Log{}
+	assert.Nil(t, c.Find(bson.M{"message": logtext}).One(&stored))
+	assert.True(t, log.Time.Equal(stored.Time))
+	assert.Equal(t, golog.ERROR, stored.Level)
+	assert.Equal(t, "mongo", stored.Logger.Name)
+	assert.Equal(t, []interface{}{map[string]interface{}{"size": int64(10)}}, stored.Data)
+}
+
+func TestMongoBatch(t *testing.T) {
+	db := "test"
+	coll := "logs"
+	logtext := "batched message"
+
+	appender, err := Mongo(golog.Conf{
+		"host":           "127.0.0.1:27017",
+		"db":             db,
+		"collection":     coll,
+		"batch_size":     "3",
+		"flush_interval": "50ms",
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer appender.Close()
+
+	c := appender.session.DB(db).C(coll)
+	c.RemoveAll(bson.M{"message": logtext})
+
+	count := func() int {
+		n, err := c.Find(bson.M{"message": logtext}).Count()
+		assert.Nil(t, err)
+		return n
+	}
+
+	appender.Append(golog.Log{Message: logtext})
+	appender.Append(golog.Log{Message: logtext})
+	assert.Exactly(t, 0, count())
+
+	// batch is full
+	appender.Append(golog.Log{Message: logtext})
+	assert.Exactly(t, 3, count())
+
+	// batch is flushed by timer
+	appender.Append(golog.Log{Message: logtext})
+	time.Sleep(200 * time.Millisecond)
+	assert.Exactly(t, 4, count())
+}
+
+func TestMongoDuplicate(t *testing.T) {
+	db := "test"
+	coll := "logs"
+	logtext := "duplicated message"
+
+	appender, err := Mongo(golog.Conf{
+		"host":       "127.0.0.1:27017",
+		"db":         db,
+		"collection": coll,
+		"batch_size": "3",
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer appender.Close()
+
+	c := appender.session.DB(db).C(coll)
+	c.RemoveAll(bson.M{"message": logtext})
+
+	first, _ := id.GenerateOrderedBytes()
+	second, _ := id.GenerateOrderedBytes()
+
+	// first log is delivered twice
+	appender.Append(golog.Log{ID: first, Message: logtext})
+	appender.Append(golog.Log{ID: first, Message: logtext})
+	appender.Append(golog.Log{ID: second, Message: logtext})
+	assert.Nil(t, appender.Flush())
+
+	var logs []golog.Log
+	assert.Nil(t, c.Find(bson.M{"message": logtext}).Sort("_id").All(&logs))
+	if assert.Len(t, logs, 2) {
+		assert.Equal(t, first, logs[0].ID)
+		assert.Equal(t, second, logs[1].ID)
+	}
+}
+
+func TestRetention(t *testing.T) {
+	info, ttl, err := retention(golog.Conf{})
+	assert.Nil(t, err)
+	assert.Nil(t, info)
+	assert.Equal(t, time.Duration(0), ttl)
+
+	info, _, err = retention(golog.Conf{"capped_size": "1048576", "capped_max": "1000"})
+	assert.Nil(t, err)
+	assert.Equal(t, &mgo.CollectionInfo{Capped: true, MaxBytes: 1048576, MaxDocs: 1000}, info)
+
+	info, ttl, err = retention(golog.Conf{"ttl": "720h"})
+	assert.Nil(t, err)
+	assert.Nil(t, info)
+	assert.Equal(t, 720*time.Hour, ttl)
+
+	for _, cnf := range []golog.Conf{
+		{"capped_max": "1000"},
+		{"capped_size": "big"},
+		{"capped_size": "-1"},
+		{"capped_size": "1024", "capped_max": "0"},
+		{"capped_size": "1024", "ttl": "1h"},
+		{"ttl": "1ms"},
+		{"ttl": "forever"},
+	} {
+		_, _, err = retention(cnf)
+		assert.NotNil(t, err, "%v", cnf)
+	}
+}
+
+func TestMongoCollection(t *testing.T) {
+	db := "test"
+	cnf := golog.Conf{
+		"host":        "127.0.0.1:27017",
+		"db":          db,
+		"collection":  "capped_logs",
+		"capped_size": "1048576",
+		"capped_max":  "10",
+	}
+
+	appender, err := Mongo(cnf)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer appender.Close()
+
+	database := appender.session.DB(db)
+	database.C("capped_logs").DropCollection()
+	database.C("ttl_logs").DropCollection()
+
+	// collection is created again, indexes are kept
+	for i := 0; i < 2; i++ {
+		appender, err = Mongo(cnf)
+		assert.Nil(t, err)
+		appender.Close()
+	}
+
+	var result struct{ Capped bool }
+	err = database.Run(bson.D{{Name: "collStats", Value: "capped_logs"}}, &result)
+	assert.Nil(t, err)
+	assert.True(t, result.Capped)
+
+	indexes, err := database.C("capped_logs").Indexes()
+	assert.Nil(t, err)
+	var keys []string
+	for _, index := range indexes {
+		keys = append(keys, index.Key...)
+	}
+	assert.Subset(t, keys, []string{"level", "logger.name", "time"})
+
+	// ttl of existing index is changed
+	for _, ttl := range []string{"1h", "2h"} {
+		appender, err = Mongo(golog.Conf{
+			"host":       "127.0.0.1:27017",
+			"db":         db,
+			"collection": "ttl_logs",
+			"ttl":        ttl,
+		})
+		assert.Nil(t, err)
+		appender.Close()
+	}
+
+	indexes, err = database.C("ttl_logs").Indexes()
+	assert.Nil(t, err)
+	for _, index := range indexes {
+		if index.Key[0] == "time" {
+			assert.Equal(t, 2*time.Hour, index.ExpireAfter)
+		}
+	}
+}
+
+func TestMongoGolden(t *testing.T) {
+	// logs are only batched, so no server is needed
+	appender := &MongoAppender{BatchSize: 10, FlushInterval: time.Hour}
+	logGolden(goldenLogger(appender))
+	appender.timer.Stop()
+
+	if !assert.Len(t, appender.batch, 1) {
+		return
+	}
+
+	b, err := bson.Marshal(appender.batch[0])
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	doc := bson.D{}
+	assert.Nil(t, bson.Unmarshal(b, &doc))
+	assert.Equal(t, bson.D{
+		{Name: "_id", Value: bson.Binary{Kind: 0x04, Data: []byte{
+			0x01, 0x24, 0xe0, 0x53, 0x35, 0x80, 0x70, 0x00, 0x80, 0x00, 0, 0, 0, 0, 0, 0x01}}},
+		{Name: "v", Value: 1},
+		{Name: "time", Value: time.Date(2009, 11, 10, 23, 0, 0, 0, time.UTC).Local()},
+		{Name: "level", Value: 4},
+		{Name: "level_name", Value: "WARNING"},
+		{Name: "message", Value: "card declined"},
+		{Name: "pid", Value: 1234},
+		{Name: "logger", Value: bson.D{{Name: "name", Value: "golden"}}},
+		{Name: "data", Value: []interface{}{bson.D{{Name: "amount", Value: int64(10)}}}},
+	}, doc)
 }
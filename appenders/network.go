@@ -0,0 +1,46 @@
+package appenders
+
+import (
+	"encoding/json"
+	"fmt"
+
+	"github.com/ildus/golog"
+)
+
+// NetworkAppender sends logs as newline delimited JSON
+// over TCP, UDP or unix socket.
+type NetworkAppender struct {
+	conn *Pool
+}
+
+// github.com/ildus/golog/appenders/network
+func (na *NetworkAppender) Id() string {
+	return "github.com/ildus/golog/appenders/network"
+}
+
+func (na *NetworkAppender) Append(log golog.Log) {
+	line, err := json.Marshal(log)
+	if err == nil {
+		_, err = na.conn.Write(append(line, '\n'))
+	}
+
+	if err != nil {
+		fmt.Println(err.Error())
+		if log.Logger != nil && log.Logger.DoPanic {
+			panic(err)
+		}
+	}
+}
+
+// Closes underlying connections.
+func (na *NetworkAppender) Close() error {
+	return na.conn.Close()
+}
+
+// Function for creating network appender.
+// For supported configuration keys look at NewConn and NewPool.
+func Network(cnf golog.Conf) *NetworkAppender {
+	return &NetworkAppender{
+		conn: NewPool(cnf),
+	}
+}
@@ -0,0 +1,50 @@
+package appenders
+
+import (
+	"encoding/json"
+	"net"
+	"testing"
+
+	"github.com/ildus/golog"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestNetworkId(t *testing.T) {
+	appender := Network(golog.Conf{})
+	assert.Equal(t, "github.com/ildus/golog/appenders/network", appender.Id())
+}
+
+func TestNetworkAppend(t *testing.T) {
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer ln.Close()
+
+	appender := Network(golog.Conf{"addr": ln.Addr().String()})
+	defer appender.Close()
+
+	appender.Append(golog.Log{Message: "first", Level: golog.INFO})
+	appender.Append(golog.Log{Message: "second", Level: golog.ERROR})
+
+	lines := readLines(t, ln, 2)
+	for i, expected := range []string{"first", "second"} {
+		log := golog.Log{}
+		assert.Nil(t, json.Unmarshal([]byte(lines[i]), &log))
+		assert.Equal(t, expected, log.Message)
+	}
+}
+
+func TestNetworkGolden(t *testing.T) {
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer ln.Close()
+
+	appender := Network(golog.Conf{"addr": ln.Addr().String()})
+	defer appender.Close()
+
+	logGolden(goldenLogger(appender))
+	assert.Equal(t, []string{goldenJSON + "\n"}, readLines(t, ln, 1))
+}
@@ -0,0 +1,191 @@
+package appenders
+
+import (
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+	"sync"
+
+	"github.com/ildus/golog"
+)
+
+// Balance defines how Pool chooses endpoint for a message.
+type Balance int
+
+const (
+	// messages go to first healthy endpoint in the list,
+	// following endpoints are used only when previous ones fail
+	Failover Balance = iota
+
+	// messages are distributed between healthy endpoints in turn
+	RoundRobin
+)
+
+// Pool sends messages to one of several endpoints. Every endpoint is a Conn,
+// so failed endpoints are skipped until their backoff passes and then they
+// are retried. Messages are buffered only when all endpoints fail.
+type Pool struct {
+	Balance Balance
+
+	// maximum number of bytes kept while all endpoints are unavailable
+	BufferSize int
+
+	mu     sync.Mutex
+	conns  []*Conn
+	next   int
+	err    error
+	buffer messageBuffer
+}
+
+// Pool has no endpoints to send messages to.
+var ErrNoEndpoints = errors.New("no endpoints configured")
+
+// All endpoints wait for backoff after failed dials.
+var ErrUnavailable = errors.New("all endpoints are unavailable")
+
+// Write sends b as one message to one of endpoints. If all endpoints fail,
+// but message fits into buffer, it will be sent later and no error is returned.
+func (p *Pool) Write(b []byte) (int, error) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	if p.err != nil {
+		return 0, p.err
+	}
+
+	// buffered messages are sent first to keep order
+	err := p.buffer.flush(p.send)
+	if err == nil {
+		if _, err = p.send(b); err == nil {
+			return len(b), nil
+		}
+	}
+
+	if p.buffer.push(b, p.BufferSize) {
+		return len(b), nil
+	}
+	return 0, err
+}
+
+// message which was sent partly to failed endpoint is sent whole
+// to next one, so it's counted as not sent
+func (p *Pool) send(b []byte) (int, error) {
+	if len(p.conns) == 0 {
+		return 0, ErrNoEndpoints
+	}
+
+	start := 0
+	if p.Balance == RoundRobin {
+		start = p.next
+	}
+
+	var errs []string
+	for i := range p.conns {
+		idx := (start + i) % len(p.conns)
+		conn := p.conns[idx]
+
+		// endpoints in backoff are skipped, they will be tried again later
+		if !conn.Healthy() {
+			continue
+		}
+
+		if _, err := conn.Write(b); err != nil {
+			errs = append(errs, err.Error())
+			continue
+		}
+
+		if p.Balance == RoundRobin {
+			p.next = (idx + 1) % len(p.conns)
+		}
+		return len(b), nil
+	}
+
+	if len(errs) == 0 {
+		return 0, ErrUnavailable
+	}
+	return 0, errors.New(strings.Join(errs, "; "))
+}
+
+// Endpoints returns connections to all endpoints.
+func (p *Pool) Endpoints() []*Conn {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	return append([]*Conn(nil), p.conns...)
+}
+
+// Dropped returns number of messages which were lost
+// because buffer was full.
+func (p *Pool) Dropped() int64 {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	return p.buffer.dropped
+}
+
+// Close closes connections to all endpoints and discards buffered messages.
+func (p *Pool) Close() error {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	p.buffer.reset()
+
+	var err error
+	for _, conn := range p.conns {
+		if closeErr := conn.Close(); err == nil {
+			err = closeErr
+		}
+	}
+	return err
+}
+
+// NewPool creates pool using appender configuration. "addr" can contain
+// comma separated list of endpoints, and "balance" can be "failover" (default)
+// or "round_robin". Other keys are described in NewConn and are applied
+// to every endpoint, except "buffer_size" which is applied to the whole pool.
+// Errors in configuration are returned from every Write.
+func NewPool(cnf golog.Conf) *Pool {
+	p := &Pool{}
+
+	switch cnf["balance"] {
+	case "", "failover":
+		p.Balance = Failover
+	case "round_robin":
+		p.Balance = RoundRobin
+	default:
+		p.err = fmt.Errorf("Unsupported balance %q", cnf["balance"])
+	}
+
+	if val := cnf["buffer_size"]; val != "" && p.err == nil {
+		size, err := strconv.Atoi(val)
+		if err != nil {
+			p.err = fmt.Errorf("Invalid buffer_size %q: %s", val, err)
+		}
+		p.BufferSize = size
+	}
+
+	for _, addr := range strings.Split(cnf["addr"], ",") {
+		addr = strings.TrimSpace(addr)
+		if addr == "" {
+			continue
+		}
+
+		endpoint := golog.Conf{}
+		for key, val := range cnf {
+			endpoint[key] = val
+		}
+		endpoint["addr"] = addr
+		delete(endpoint, "buffer_size")
+
+		conn := NewConn(endpoint)
+		// configuration is the same for all endpoints, so its
+		// errors are returned from Write instead of ErrUnavailable
+		if conn.err != nil && p.err == nil {
+			p.err = conn.err
+		}
+		p.conns = append(p.conns, conn)
+	}
+
+	return p
+}
@@ -0,0 +1,226 @@
+package appenders
+
+import (
+	"net"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/ildus/golog"
+	"github.com/ildus/golog/heka_emitter/hekatest"
+	"github.com/stretchr/testify/assert"
+)
+
+func newReceivers(t *testing.T, count int) ([]*hekatest.Receiver, []string) {
+	var (
+		receivers []*hekatest.Receiver
+		addrs     []string
+	)
+
+	for i := 0; i < count; i++ {
+		receiver, err := hekatest.NewReceiver("tcp")
+		if err != nil {
+			t.Fatal(err)
+		}
+		receivers = append(receivers, receiver)
+		addrs = append(addrs, receiver.Addr)
+	}
+	return receivers, addrs
+}
+
+// appends messages until receiver gets one of them
+func appendUntilReceived(t *testing.T, appender golog.Appender, receiver *hekatest.Receiver) {
+	deadline := time.Now().Add(5 * time.Second)
+	for len(receiver.Messages()) == 0 {
+		if time.Now().After(deadline) {
+			t.Fatal("Message is not received")
+		}
+		appender.Append(golog.Log{Message: "retry"})
+		time.Sleep(time.Millisecond)
+	}
+}
+
+func TestPoolConf(t *testing.T) {
+	pool := NewPool(golog.Conf{
+		"addr":        "127.0.0.1:5565, 127.0.0.1:5566,",
+		"balance":     "round_robin",
+		"buffer_size": "1024",
+		"min_backoff": "10ms",
+	})
+
+	assert.Nil(t, pool.err)
+	assert.Equal(t, RoundRobin, pool.Balance)
+	assert.Equal(t, 1024, pool.BufferSize)
+
+	endpoints := pool.Endpoints()
+	if assert.Len(t, endpoints, 2) {
+		assert.Equal(t, "127.0.0.1:5565", endpoints[0].Addr)
+		assert.Equal(t, "127.0.0.1:5566", endpoints[1].Addr)
+		assert.Equal(t, 10*time.Millisecond, endpoints[1].MinBackoff)
+		// buffer is shared by endpoints
+		assert.Equal(t, 0, endpoints[1].BufferSize)
+	}
+
+	pool = NewPool(golog.Conf{"addr": "127.0.0.1:5565"})
+	assert.Equal(t, Failover, pool.Balance)
+
+	pool = NewPool(golog.Conf{"addr": "127.0.0.1:5565", "balance": "random"})
+	_, err := pool.Write([]byte("msg"))
+	assert.NotNil(t, err)
+
+	_, err = NewPool(golog.Conf{}).Write([]byte("msg"))
+	assert.Equal(t, ErrNoEndpoints, err)
+
+	// errors in configuration of endpoints are not reported as unavailability
+	pool = NewPool(golog.Conf{"addr": "127.0.0.1:5565", "max_backoff": "soon"})
+	_, err = pool.Write([]byte("msg"))
+	if assert.NotNil(t, err) {
+		assert.NotEqual(t, ErrUnavailable, err)
+		assert.Contains(t, err.Error(), "max_backoff")
+	}
+
+	pool = NewPool(golog.Conf{"addr": "127.0.0.1:5565", "tls": "true", "tls_ca": "/nonexistent/ca.pem"})
+	_, err = pool.Write([]byte("msg"))
+	if assert.NotNil(t, err) {
+		assert.NotEqual(t, ErrUnavailable, err)
+	}
+}
+
+func TestPoolFailover(t *testing.T) {
+	receivers, addrs := newReceivers(t, 2)
+	defer receivers[1].Close()
+
+	appender := Heka(golog.Conf{
+		"addr":        strings.Join(addrs, ","),
+		"min_backoff": "1ms",
+	})
+	defer appender.Close()
+
+	for i := 0; i < 3; i++ {
+		appender.Append(golog.Log{Message: "primary"})
+	}
+
+	_, err := receivers[0].Wait(3, 5*time.Second)
+	assert.Nil(t, err)
+	assert.Empty(t, receivers[1].Messages())
+
+	// secondary takes over when primary dies,
+	// messages written before broken connection is noticed can be lost
+	receivers[0].Close()
+	appendUntilReceived(t, appender, receivers[1])
+
+	receivers[1].Reset()
+	appender.Append(golog.Log{Message: "secondary"})
+
+	messages, err := receivers[1].Wait(1, 5*time.Second)
+	assert.Nil(t, err)
+	assert.Equal(t, "secondary", messages[0].GetPayload())
+}
+
+func TestPoolRoundRobin(t *testing.T) {
+	receivers, addrs := newReceivers(t, 3)
+	defer receivers[1].Close()
+	defer receivers[2].Close()
+
+	appender := Heka(golog.Conf{
+		"addr":        strings.Join(addrs, ","),
+		"balance":     "round_robin",
+		"min_backoff": "1m",
+	})
+	defer appender.Close()
+
+	for i := 0; i < 6; i++ {
+		appender.Append(golog.Log{Message: "balanced"})
+	}
+
+	for _, receiver := range receivers {
+		_, err := receiver.Wait(2, 5*time.Second)
+		assert.Nil(t, err)
+	}
+
+	// dead endpoint is skipped after failure is noticed, others share its load
+	receivers[0].Close()
+	dead := appender.conn.Endpoints()[0]
+	deadline := time.Now().Add(5 * time.Second)
+	for dead.Healthy() {
+		if time.Now().After(deadline) {
+			t.Fatal("Failure is not noticed")
+		}
+		appender.Append(golog.Log{Message: "retry"})
+		time.Sleep(time.Millisecond)
+	}
+
+	for _, receiver := range receivers {
+		receiver.Reset()
+	}
+
+	for i := 0; i < 4; i++ {
+		appender.Append(golog.Log{Message: "balanced"})
+	}
+
+	for _, receiver := range receivers[1:] {
+		messages, err := receiver.Wait(2, 5*time.Second)
+		assert.Nil(t, err)
+		assert.Len(t, messages, 2)
+	}
+	assert.Empty(t, receivers[0].Messages())
+}
+
+func TestPoolRetry(t *testing.T) {
+	primary := unusedAddr(t)
+	secondary, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer secondary.Close()
+
+	pool := NewPool(golog.Conf{
+		"addr":        primary + "," + secondary.Addr().String(),
+		"min_backoff": "1ms",
+		"max_backoff": "1ms",
+	})
+	defer pool.Close()
+
+	_, err = pool.Write([]byte("first\n"))
+	assert.Nil(t, err)
+	assert.Equal(t, []string{"first\n"}, readLines(t, secondary, 1))
+
+	// primary is used again after it recovers
+	ln, err := net.Listen("tcp", primary)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer ln.Close()
+
+	time.Sleep(5 * time.Millisecond)
+	_, err = pool.Write([]byte("second\n"))
+	assert.Nil(t, err)
+	assert.Equal(t, []string{"second\n"}, readLines(t, ln, 1))
+}
+
+func TestPoolBuffer(t *testing.T) {
+	addr := unusedAddr(t)
+	pool := NewPool(golog.Conf{
+		"addr":        unusedAddr(t) + "," + addr,
+		"min_backoff": "1ms",
+		"max_backoff": "1ms",
+		"buffer_size": "1024",
+	})
+	defer pool.Close()
+
+	// all endpoints are down, so messages are kept in buffer
+	_, err := pool.Write([]byte("first\n"))
+	assert.Nil(t, err)
+
+	ln, err := net.Listen("tcp", addr)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer ln.Close()
+
+	time.Sleep(5 * time.Millisecond)
+	_, err = pool.Write([]byte("second\n"))
+	assert.Nil(t, err)
+	assert.Equal(t, []string{"first\n", "second\n"}, readLines(t, ln, 2))
+	assert.Equal(t, int64(0), pool.Dropped())
+}
@@ -0,0 +1,137 @@
+package golog_test
+
+import (
+	"io"
+	"io/ioutil"
+	"net"
+	"os"
+	"path/filepath"
+	"runtime"
+	"testing"
+	"time"
+
+	"github.com/ildus/golog"
+	"github.com/ildus/golog/appenders"
+	"gopkg.in/mgo.v2"
+)
+
+// Benchmarks of appenders from appenders package, they are external
+// tests, because appenders package imports golog. Benchmarks of logger
+// and console appenders are in logger_test.go.
+
+// sends b.N logs with data to appender
+func benchmarkAppender(b *testing.B, appender golog.Appender) {
+	logger := &golog.Logger{Name: "github.com/ildus/golog/bench", Level: golog.INFO}
+	logger.Enable(appender)
+	data := map[string]int{"size": 10}
+
+	b.ReportAllocs()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		logger.Info("some msg", data)
+	}
+}
+
+// listens on TCP and discards everything it receives
+func discardListener(b *testing.B) net.Listener {
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		b.Fatal(err)
+	}
+
+	go func() {
+		for {
+			conn, err := ln.Accept()
+			if err != nil {
+				return
+			}
+			go io.Copy(ioutil.Discard, conn)
+		}
+	}()
+	return ln
+}
+
+func BenchmarkFileAppender(b *testing.B) {
+	dir, err := ioutil.TempDir("", "golog-bench")
+	if err != nil {
+		b.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	benchmarkAppender(b, appenders.File(golog.Conf{"path": filepath.Join(dir, "log.txt")}))
+}
+
+func BenchmarkNetworkAppender(b *testing.B) {
+	ln := discardListener(b)
+	defer ln.Close()
+
+	appender := appenders.Network(golog.Conf{"addr": ln.Addr().String()})
+	defer appender.Close()
+
+	benchmarkAppender(b, appender)
+}
+
+func BenchmarkHekaAppender(b *testing.B) {
+	ln := discardListener(b)
+	defer ln.Close()
+
+	appender := appenders.Heka(golog.Conf{"addr": ln.Addr().String(), "hostname": "example.com"})
+	defer appender.Close()
+
+	benchmarkAppender(b, appender)
+}
+
+func BenchmarkJournaldAppender(b *testing.B) {
+	if runtime.GOOS != "linux" {
+		b.Skip("journald is available only on linux")
+	}
+
+	dir, err := ioutil.TempDir("", "golog-bench")
+	if err != nil {
+		b.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	path := filepath.Join(dir, "socket")
+	conn, err := net.ListenUnixgram("unixgram", &net.UnixAddr{Name: path, Net: "unixgram"})
+	if err != nil {
+		b.Fatal(err)
+	}
+	defer conn.Close()
+
+	go func() {
+		buf := make([]byte, 4096)
+		for {
+			if _, err := conn.Read(buf); err != nil {
+				return
+			}
+		}
+	}()
+
+	appender := appenders.Journald(golog.Conf{"socket": path})
+	defer appender.Close()
+
+	benchmarkAppender(b, appender)
+}
+
+func BenchmarkMongoAppender(b *testing.B) {
+	session, err := mgo.DialWithTimeout("127.0.0.1:27017", time.Second)
+	if err != nil {
+		b.Skip(err)
+	}
+	defer session.Close()
+	defer session.DB("test").C("bench").DropCollection()
+
+	appender, err := appenders.Mongo(golog.Conf{
+		"host":       "127.0.0.1:27017",
+		"db":         "test",
+		"collection": "bench",
+		"batch_size": "100",
+	})
+	if err != nil {
+		b.Fatal(err)
+	}
+	defer appender.Close()
+
+	benchmarkAppender(b, appender)
+}
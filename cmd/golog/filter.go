@@ -0,0 +1,165 @@
+package main
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/ildus/golog"
+)
+
+// expression compares value of log field with constant,
+// e.g. "user=alice" or "amount>=100"
+type expression struct {
+	field string
+	op    string
+	value string
+}
+
+// two character operators go first, so they are not taken for one character ones
+var operators = []string{"!=", ">=", "<=", "!~", "=", "~", ">", "<"}
+
+func parseExpression(s string) (*expression, error) {
+	for i := range s {
+		for _, op := range operators {
+			if !strings.HasPrefix(s[i:], op) {
+				continue
+			}
+
+			e := &expression{
+				field: strings.TrimSpace(s[:i]),
+				op:    op,
+				value: strings.TrimSpace(s[i+len(op):]),
+			}
+			if e.field == "" {
+				return nil, fmt.Errorf("Missing field name in expression %q", s)
+			}
+			return e, nil
+		}
+	}
+	return nil, fmt.Errorf("Missing operator in expression %q", s)
+}
+
+// match reports if log matches expression,
+// only negative operators match logs without field
+func (e *expression) match(log golog.Log) bool {
+	value, ok := lookup(log, e.field)
+	if !ok {
+		return e.op == "!=" || e.op == "!~"
+	}
+
+	// levels are compared by severity
+	if e.field == "level" {
+		if level, err := golog.ParseLevel(e.value); err == nil {
+			return compare(float64(log.Level), float64(level), e.op)
+		}
+	}
+
+	switch e.op {
+	case "~":
+		return strings.Contains(value, e.value)
+	case "!~":
+		return !strings.Contains(value, e.value)
+	}
+
+	// numbers are compared by value, other values as strings
+	a, errA := strconv.ParseFloat(value, 64)
+	b, errB := strconv.ParseFloat(e.value, 64)
+	if errA == nil && errB == nil {
+		return compare(a, b, e.op)
+	}
+	return compare(float64(strings.Compare(value, e.value)), 0, e.op)
+}
+
+func compare(a, b float64, op string) bool {
+	switch op {
+	case "=":
+		return a == b
+	case "!=":
+		return a != b
+	case ">":
+		return a > b
+	case ">=":
+		return a >= b
+	case "<":
+		return a < b
+	case "<=":
+		return a <= b
+	}
+	return false
+}
+
+// lookup returns value of log field, fields are "msg", "level", "logger",
+// "pid", "time" and fields of log data as they are named in logfmt output
+func lookup(log golog.Log, field string) (string, bool) {
+	switch field {
+	case "msg", "message":
+		return log.Message, true
+	case "level":
+		return strings.ToLower(log.Level.String()), true
+	case "logger":
+		if log.Logger == nil {
+			return "", false
+		}
+		return log.Logger.Name, true
+	case "pid":
+		return strconv.Itoa(log.Pid), true
+	case "time":
+		return log.Time.Format(time.RFC3339Nano), true
+	}
+
+	for _, f := range dataFields(log.Data) {
+		if f.name == field {
+			return f.value, true
+		}
+	}
+	return "", false
+}
+
+func matchAll(expressions []*expression, log golog.Log) bool {
+	for _, e := range expressions {
+		if !e.match(log) {
+			return false
+		}
+	}
+	return true
+}
+
+// expressions collects repeated -where flags
+type expressions []*expression
+
+func (es *expressions) String() string {
+	parts := make([]string, len(*es))
+	for i, e := range *es {
+		parts[i] = e.field + e.op + e.value
+	}
+	return strings.Join(parts, " ")
+}
+
+func (es *expressions) Set(s string) error {
+	e, err := parseExpression(s)
+	if err != nil {
+		return err
+	}
+	*es = append(*es, e)
+	return nil
+}
+
+// parseTime accepts RFC3339 time or duration before now,
+// empty string means no limit
+func parseTime(s string, now time.Time) (time.Time, error) {
+	if s == "" {
+		return time.Time{}, nil
+	}
+
+	if d, err := time.ParseDuration(s); err == nil {
+		return now.Add(-d), nil
+	}
+
+	t, err := time.Parse(time.RFC3339Nano, s)
+	if err != nil {
+		return time.Time{}, fmt.Errorf("Invalid time %q, expected RFC3339 time or duration", s)
+	}
+	return t, nil
+}
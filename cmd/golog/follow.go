@@ -0,0 +1,159 @@
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"io"
+	"os"
+	"time"
+)
+
+// follower reads file as it grows. When file at path is replaced
+// (rotated), the rest of old file is read and new file is opened.
+// Truncated file is read again from the beginning.
+type follower struct {
+	path   string
+	poll   time.Duration
+	stop   <-chan struct{}
+	line   func([]byte) error
+	file   *os.File
+	info   os.FileInfo
+	reader *bufio.Reader
+	offset int64
+	// beginning of line which isn't written completely yet
+	partial []byte
+}
+
+func followFile(path string, poll time.Duration, stop <-chan struct{}, p *printer) error {
+	f := &follower{path: path, poll: poll, stop: stop, line: p.line}
+	defer f.close()
+
+	return f.run()
+}
+
+func (f *follower) run() error {
+	// file can appear later
+	for f.file == nil {
+		err := f.open()
+		if err == nil {
+			break
+		}
+		if !os.IsNotExist(err) {
+			return err
+		}
+		if !f.wait() {
+			return nil
+		}
+	}
+
+	for {
+		if err := f.read(); err != nil {
+			return err
+		}
+		if !f.wait() {
+			return nil
+		}
+
+		info, err := os.Stat(f.path)
+		switch {
+		case err != nil:
+			// file is moved away and new one isn't created yet
+			continue
+		case !os.SameFile(f.info, info):
+			// old file can still get writes made before rotation
+			if err := f.read(); err != nil {
+				return err
+			}
+			if err := f.flushPartial(); err != nil {
+				return err
+			}
+			f.close()
+			if err := f.open(); err != nil && !os.IsNotExist(err) {
+				return err
+			}
+		case info.Size() < f.offset:
+			if _, err := f.file.Seek(0, io.SeekStart); err != nil {
+				return err
+			}
+			f.reader.Reset(f.file)
+			f.offset = 0
+			f.partial = nil
+		}
+	}
+}
+
+func (f *follower) open() error {
+	file, err := os.Open(f.path)
+	if err != nil {
+		return err
+	}
+
+	info, err := file.Stat()
+	if err != nil {
+		file.Close()
+		return err
+	}
+
+	f.file, f.info = file, info
+	f.reader = bufio.NewReader(file)
+	f.offset = 0
+	return nil
+}
+
+func (f *follower) close() {
+	if f.file != nil {
+		f.file.Close()
+		f.file = nil
+	}
+}
+
+// read processes lines appended to file
+func (f *follower) read() error {
+	if f.file == nil {
+		return nil
+	}
+
+	for {
+		chunk, err := f.reader.ReadBytes('\n')
+		f.offset += int64(len(chunk))
+
+		if err == nil {
+			line := chunk
+			if len(f.partial) > 0 {
+				line = append(f.partial, chunk...)
+				f.partial = nil
+			}
+			if err := f.line(line); err != nil {
+				return err
+			}
+			continue
+		}
+
+		f.partial = append(f.partial, chunk...)
+		if err == io.EOF {
+			return nil
+		}
+		return err
+	}
+}
+
+// flushPartial processes last line of file which has no new line
+func (f *follower) flushPartial() error {
+	line := f.partial
+	f.partial = nil
+
+	if len(bytes.TrimSpace(line)) == 0 {
+		return nil
+	}
+	return f.line(line)
+}
+
+// wait sleeps for poll interval, it returns false if following is stopped
+func (f *follower) wait() bool {
+	select {
+	case <-f.stop:
+		return false
+	case <-time.After(f.poll):
+		return true
+	}
+}
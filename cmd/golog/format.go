@@ -0,0 +1,114 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+	"unicode/utf8"
+
+	"github.com/ildus/golog"
+)
+
+// formatter appends log to buf
+type formatter func(buf []byte, log golog.Log, color bool) []byte
+
+// formatText uses layout and colors of Stdout appender
+func formatText(buf []byte, log golog.Log, color bool) []byte {
+	if color {
+		return golog.AppendColorText(buf, log)
+	}
+	return golog.AppendText(buf, log)
+}
+
+// formatJSON uses schema of File appender
+func formatJSON(buf []byte, log golog.Log, color bool) []byte {
+	line, err := json.Marshal(log)
+	if err != nil {
+		// logs read from JSON can always be encoded back
+		panic(err)
+	}
+	return append(buf, line...)
+}
+
+func formatLogfmt(buf []byte, log golog.Log, color bool) []byte {
+	buf = appendLogfmt(buf, "time", log.Time.Format(time.RFC3339Nano))
+	buf = appendLogfmt(append(buf, ' '), "level", strings.ToLower(log.Level.String()))
+	if log.Logger != nil {
+		buf = appendLogfmt(append(buf, ' '), "logger", log.Logger.Name)
+	}
+	buf = appendLogfmt(append(buf, ' '), "msg", log.Message)
+	if log.Pid != 0 {
+		buf = appendLogfmt(append(buf, ' '), "pid", strconv.Itoa(log.Pid))
+	}
+
+	for _, f := range dataFields(log.Data) {
+		buf = appendLogfmt(append(buf, ' '), f.name, f.value)
+	}
+	return buf
+}
+
+func appendLogfmt(buf []byte, key, value string) []byte {
+	buf = append(buf, key...)
+	buf = append(buf, '=')
+
+	if value == "" || strings.IndexFunc(value, needsQuote) >= 0 {
+		return strconv.AppendQuote(buf, value)
+	}
+	return append(buf, value...)
+}
+
+func needsQuote(r rune) bool {
+	return r <= ' ' || r == '=' || r == '"' || r == utf8.RuneError
+}
+
+type field struct {
+	name  string
+	value string
+}
+
+// dataFields flattens log data: keys of maps are joined with dots, items of
+// slices are numbered, values which aren't maps are named "data.N"
+func dataFields(data []interface{}) []field {
+	var fields []field
+	for i, item := range data {
+		if obj, ok := item.(map[string]interface{}); ok {
+			fields = appendFields(fields, "", obj)
+		} else {
+			fields = appendFields(fields, "data."+strconv.Itoa(i), item)
+		}
+	}
+	return fields
+}
+
+func appendFields(fields []field, name string, value interface{}) []field {
+	prefix := name
+	if prefix != "" {
+		prefix += "."
+	}
+
+	switch val := value.(type) {
+	case map[string]interface{}:
+		keys := make([]string, 0, len(val))
+		for key := range val {
+			keys = append(keys, key)
+		}
+		sort.Strings(keys)
+
+		for _, key := range keys {
+			fields = appendFields(fields, prefix+key, val[key])
+		}
+		return fields
+	case []interface{}:
+		for i, item := range val {
+			fields = appendFields(fields, prefix+strconv.Itoa(i), item)
+		}
+		return fields
+	case nil:
+		return append(fields, field{name, ""})
+	}
+
+	return append(fields, field{name, fmt.Sprint(value)})
+}
@@ -0,0 +1,272 @@
+// Command golog reads JSON lines written by golog's File appender, filters
+// them and prints them as text in the same layout as Stdout appender,
+// or as JSON or logfmt.
+//
+// Usage:
+//
+//	golog [flags] [file ...]
+//
+// Logs are read from stdin if no files are given or file is "-".
+// With -f files are followed like with "tail -n +1 -f", rotated files
+// are reopened. Examples:
+//
+//	golog -f -level warning /var/log/app.log
+//	golog -logger 'payments.*' -since 1h -where 'amount>100' app.log
+//	golog -output logfmt -where 'user=alice' < app.log
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"os/signal"
+	"sync"
+	"time"
+
+	"github.com/ildus/golog"
+	"github.com/ildus/golog/query"
+)
+
+func main() {
+	os.Exit(run(os.Args[1:], os.Stdin, os.Stdout, os.Stderr))
+}
+
+type options struct {
+	filter query.Filter
+	where  []*expression
+	follow bool
+	poll   time.Duration
+	format formatter
+	color  bool
+}
+
+func run(args []string, stdin io.Reader, stdout, stderr io.Writer) int {
+	opts, files, err := parseArgs(args, stdout, stderr)
+	if err == flag.ErrHelp {
+		return 0
+	}
+	if err != nil {
+		fmt.Fprintln(stderr, "golog:", err)
+		return 2
+	}
+
+	out := &printer{w: bufio.NewWriter(stdout), opts: opts}
+	stop := make(chan struct{})
+
+	if opts.follow {
+		signals := make(chan os.Signal, 1)
+		signal.Notify(signals, os.Interrupt)
+		defer signal.Stop(signals)
+
+		go func() {
+			<-signals
+			close(stop)
+		}()
+	}
+
+	if len(files) == 0 {
+		files = []string{"-"}
+	}
+
+	var (
+		wg     sync.WaitGroup
+		mu     sync.Mutex
+		failed bool
+	)
+
+	for _, path := range files {
+		read := func(path string) error {
+			if path == "-" {
+				return readLines(stdin, path, out)
+			}
+			if opts.follow {
+				return followFile(path, opts.poll, stop, out)
+			}
+
+			f, err := os.Open(path)
+			if err != nil {
+				return err
+			}
+			defer f.Close()
+			return readLines(f, path, out)
+		}
+
+		// followed files are read concurrently
+		if !opts.follow {
+			if err := read(path); err != nil {
+				fmt.Fprintln(stderr, "golog:", err)
+				failed = true
+			}
+			continue
+		}
+
+		wg.Add(1)
+		go func(path string) {
+			defer wg.Done()
+			if err := read(path); err != nil {
+				fmt.Fprintln(stderr, "golog:", err)
+				mu.Lock()
+				failed = true
+				mu.Unlock()
+			}
+		}(path)
+	}
+
+	wg.Wait()
+	out.flush()
+
+	if out.skipped > 0 {
+		fmt.Fprintf(stderr, "golog: skipped %d lines which are not logs\n", out.skipped)
+	}
+	if failed {
+		return 1
+	}
+	return 0
+}
+
+func parseArgs(args []string, stdout, stderr io.Writer) (*options, []string, error) {
+	fs := flag.NewFlagSet("golog", flag.ContinueOnError)
+	fs.SetOutput(stderr)
+	fs.Usage = func() {
+		fmt.Fprintln(stderr, "Usage: golog [flags] [file ...]")
+		fs.PrintDefaults()
+	}
+
+	var (
+		level  = fs.String("level", "", "show logs of this `level` and more severe ones")
+		logger = fs.String("logger", "", "show logs of loggers with names matching `pattern` (\"*\" and \"?\" wildcards)")
+		since  = fs.String("since", "", "show logs made after `time` (RFC3339 or duration before now, e.g. 1h)")
+		until  = fs.String("until", "", "show logs made before `time` (RFC3339 or duration before now)")
+		grep   = fs.String("grep", "", "show logs with messages containing `text`")
+		output = fs.String("output", "text", "output `format`: text, json or logfmt")
+		color  = fs.String("color", "auto", "colorize text output: auto, always or never")
+		where  expressions
+		opts   = &options{}
+	)
+
+	fs.Var(&where, "where", "show logs matching `expression`, e.g. 'user=alice' or 'amount>=100',\n"+
+		"operators are = != ~ (contains) !~ > >= < <=, can be repeated")
+	fs.BoolVar(&opts.follow, "f", false, "follow files as they grow, rotated files are reopened")
+	fs.DurationVar(&opts.poll, "poll", 250*time.Millisecond, "how often followed files are checked")
+
+	if err := fs.Parse(args); err != nil {
+		return nil, nil, err
+	}
+
+	if *level != "" {
+		lvl, err := golog.ParseLevel(*level)
+		if err != nil {
+			return nil, nil, err
+		}
+		opts.filter.Levels = &query.LevelRange{From: golog.EMERGENCY, To: lvl}
+	}
+
+	now := time.Now()
+	var err error
+	if opts.filter.From, err = parseTime(*since, now); err != nil {
+		return nil, nil, err
+	}
+	if opts.filter.To, err = parseTime(*until, now); err != nil {
+		return nil, nil, err
+	}
+
+	opts.filter.Logger = *logger
+	opts.filter.Message = *grep
+	opts.where = where
+
+	switch *output {
+	case "text":
+		opts.format = formatText
+	case "json":
+		opts.format = formatJSON
+	case "logfmt":
+		opts.format = formatLogfmt
+	default:
+		return nil, nil, fmt.Errorf("Unknown output format %q", *output)
+	}
+
+	switch *color {
+	case "always":
+		opts.color = true
+	case "never":
+	case "auto":
+		opts.color = golog.ColorEnabled(stdout)
+	default:
+		return nil, nil, fmt.Errorf("Unknown color mode %q", *color)
+	}
+
+	return opts, fs.Args(), nil
+}
+
+// printer filters logs and writes them in chosen format
+type printer struct {
+	mu      sync.Mutex
+	w       *bufio.Writer
+	opts    *options
+	buf     []byte
+	skipped int
+}
+
+// line is one line of input, it can be empty
+func (p *printer) line(line []byte) error {
+	if len(bytes.TrimSpace(line)) == 0 {
+		return nil
+	}
+
+	log := golog.Log{}
+	err := json.Unmarshal(line, &log)
+
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	if err != nil {
+		p.skipped++
+		return nil
+	}
+
+	if !p.opts.filter.Match(log) || !matchAll(p.opts.where, log) {
+		return nil
+	}
+
+	p.buf = p.opts.format(p.buf[:0], log, p.opts.color)
+	p.buf = append(p.buf, '\n')
+	if _, err := p.w.Write(p.buf); err != nil {
+		return err
+	}
+
+	// followed logs are shown immediately
+	if p.opts.follow {
+		return p.w.Flush()
+	}
+	return nil
+}
+
+func (p *printer) flush() {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	p.w.Flush()
+}
+
+func readLines(r io.Reader, name string, p *printer) error {
+	reader := bufio.NewReader(r)
+	for {
+		line, err := reader.ReadBytes('\n')
+		if len(line) > 0 {
+			if err := p.line(line); err != nil {
+				return err
+			}
+		}
+
+		if err == io.EOF {
+			return nil
+		}
+		if err != nil {
+			return fmt.Errorf("Error reading %s: %s", name, err)
+		}
+	}
+}
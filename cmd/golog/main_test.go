@@ -0,0 +1,268 @@
+package main
+
+import (
+	"bytes"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/ildus/golog"
+	"github.com/ildus/golog/appenders"
+	"github.com/stretchr/testify/assert"
+)
+
+var testTime = time.Date(2009, 11, 10, 23, 0, 0, 0, time.UTC)
+
+func testLogs() []golog.Log {
+	payments := &golog.Logger{Name: "payments.api"}
+	orders := &golog.Logger{Name: "orders"}
+
+	return []golog.Log{
+		{
+			Time:    testTime,
+			Message: "charged",
+			Level:   golog.INFO,
+			Logger:  payments,
+			Pid:     42,
+			Data:    []interface{}{map[string]interface{}{"amount": 150, "user": map[string]string{"name": "alice"}}},
+		},
+		{
+			Time:    testTime.Add(time.Minute),
+			Message: "card declined",
+			Level:   golog.WARNING,
+			Logger:  payments,
+			Data:    []interface{}{map[string]interface{}{"amount": 20}, "retry"},
+		},
+		{
+			Time:    testTime.Add(time.Hour),
+			Message: "order lost",
+			Level:   golog.CRITICAL,
+			Logger:  orders,
+		},
+	}
+}
+
+// logs as they are read from file
+func decodedLogs(t *testing.T) []golog.Log {
+	var logs []golog.Log
+	for _, log := range testLogs() {
+		b, err := log.MarshalJSON()
+		if err != nil {
+			t.Fatal(err)
+		}
+		decoded := golog.Log{}
+		if err := decoded.UnmarshalJSON(b); err != nil {
+			t.Fatal(err)
+		}
+		logs = append(logs, decoded)
+	}
+	return logs
+}
+
+func writeTestLogs(t *testing.T, path string, logs []golog.Log) {
+	appender := appenders.File(golog.Conf{"path": path})
+	for _, log := range logs {
+		appender.Append(log)
+	}
+}
+
+func tempDir(t *testing.T) string {
+	dir, err := ioutil.TempDir("", "golog-cmd")
+	if err != nil {
+		t.Fatal(err)
+	}
+	return dir
+}
+
+func TestExpression(t *testing.T) {
+	logs := decodedLogs(t)
+
+	for _, c := range []struct {
+		expr    string
+		matches []bool
+	}{
+		{"amount>100", []bool{true, false, false}},
+		{"amount >= 20", []bool{true, true, false}},
+		{"amount=150.0", []bool{true, false, false}},
+		{"amount!=150", []bool{false, true, true}},
+		{"user.name=alice", []bool{true, false, false}},
+		{"data.1~ret", []bool{false, true, false}},
+		{"msg!~card", []bool{true, false, true}},
+		{"logger=orders", []bool{false, false, true}},
+		{"level<=warning", []bool{false, true, true}},
+		{"level=info", []bool{true, false, false}},
+		{"pid=42", []bool{true, false, false}},
+		{"time<2009-11-10T23:30:00Z", []bool{true, true, false}},
+	} {
+		e, err := parseExpression(c.expr)
+		if !assert.Nil(t, err, c.expr) {
+			continue
+		}
+		for i, log := range logs {
+			assert.Equal(t, c.matches[i], e.match(log), "%s %d", c.expr, i)
+		}
+	}
+
+	for _, expr := range []string{"amount", "=10", ""} {
+		_, err := parseExpression(expr)
+		assert.NotNil(t, err, expr)
+	}
+}
+
+func TestParseTime(t *testing.T) {
+	tm, err := parseTime("1h", testTime)
+	assert.Nil(t, err)
+	assert.Equal(t, testTime.Add(-time.Hour), tm)
+
+	tm, err = parseTime("2009-11-10T23:00:00Z", time.Now())
+	assert.Nil(t, err)
+	assert.True(t, testTime.Equal(tm))
+
+	tm, err = parseTime("", time.Now())
+	assert.Nil(t, err)
+	assert.True(t, tm.IsZero())
+
+	_, err = parseTime("yesterday", time.Now())
+	assert.NotNil(t, err)
+}
+
+func TestFormats(t *testing.T) {
+	log := decodedLogs(t)[1]
+
+	assert.Equal(t, "WARN payments.api [2009-11-10 23:01:00]: card declined map[amount:20]retry",
+		string(formatText(nil, log, false)))
+	assert.Equal(t, "\x1b[33mWARN\x1b[0m \x1b[2mpayments.api [2009-11-10 23:01:00]\x1b[0m: card declined map[amount:20]retry",
+		string(formatText(nil, log, true)))
+
+	assert.Equal(t, `time=2009-11-10T23:01:00Z level=warning logger=payments.api `+
+		`msg="card declined" amount=20 data.1=retry`,
+		string(formatLogfmt(nil, log, false)))
+
+	decoded := golog.Log{}
+	assert.Nil(t, decoded.UnmarshalJSON(formatJSON(nil, log, false)))
+	assert.Equal(t, "card declined", decoded.Message)
+}
+
+func TestRun(t *testing.T) {
+	dir := tempDir(t)
+	defer os.RemoveAll(dir)
+
+	path := filepath.Join(dir, "app.log")
+	writeTestLogs(t, path, testLogs())
+
+	run := func(stdin string, args ...string) (string, string, int) {
+		stdout, stderr := new(bytes.Buffer), new(bytes.Buffer)
+		code := run(args, strings.NewReader(stdin), stdout, stderr)
+		return stdout.String(), stderr.String(), code
+	}
+
+	out, _, code := run("", "-level", "warning", path)
+	assert.Equal(t, 0, code)
+	assert.Equal(t, "WARN payments.api [2009-11-10 23:01:00]: card declined map[amount:20]retry\n"+
+		"CRIT orders [2009-11-11 00:00:00]: order lost\n", out)
+
+	out, _, _ = run("", "-logger", "payments.*", "-where", "amount>100", "-output", "logfmt", path)
+	assert.Equal(t, "time=2009-11-10T23:00:00Z level=info logger=payments.api msg=charged pid=42 "+
+		"amount=150 user.name=alice\n", out)
+
+	out, _, _ = run("", "-since", "2009-11-10T23:00:30Z", "-until", "2009-11-10T23:30:00Z", "-grep", "card", path)
+	assert.Equal(t, 1, strings.Count(out, "\n"))
+
+	// logs are read from stdin, lines which aren't logs are skipped
+	content, _ := ioutil.ReadFile(path)
+	out, errOut, code := run("not a log\n"+string(content), "-output", "json", "-where", "logger=orders")
+	assert.Equal(t, 0, code)
+	assert.Contains(t, out, `"message":"order lost"`)
+	assert.Equal(t, 1, strings.Count(out, "\n"))
+	assert.Contains(t, errOut, "skipped 1 lines")
+
+	_, _, code = run("", filepath.Join(dir, "missing.log"))
+	assert.Equal(t, 1, code)
+
+	_, _, code = run("", "-level", "verbose")
+	assert.Equal(t, 2, code)
+
+	_, _, code = run("", "-output", "xml")
+	assert.Equal(t, 2, code)
+}
+
+// collects printed lines
+type lineRecorder struct {
+	mu    sync.Mutex
+	lines []string
+}
+
+func (r *lineRecorder) line(line []byte) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	r.lines = append(r.lines, strings.TrimSpace(string(line)))
+	return nil
+}
+
+func (r *lineRecorder) wait(t *testing.T, count int) []string {
+	deadline := time.Now().Add(5 * time.Second)
+	for time.Now().Before(deadline) {
+		r.mu.Lock()
+		lines := append([]string(nil), r.lines...)
+		r.mu.Unlock()
+
+		if len(lines) >= count {
+			return lines
+		}
+		time.Sleep(time.Millisecond)
+	}
+	t.Fatalf("Expected %d lines", count)
+	return nil
+}
+
+func TestFollow(t *testing.T) {
+	dir := tempDir(t)
+	defer os.RemoveAll(dir)
+
+	path := filepath.Join(dir, "app.log")
+	write := func(path, s string) {
+		f, err := os.OpenFile(path, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0666)
+		if err != nil {
+			t.Fatal(err)
+		}
+		f.WriteString(s)
+		f.Close()
+	}
+
+	stop := make(chan struct{})
+	done := make(chan error)
+	recorder := &lineRecorder{}
+	f := &follower{path: path, poll: time.Millisecond, stop: stop, line: recorder.line}
+	go func() {
+		done <- f.run()
+	}()
+
+	// file is created later
+	write(path, "first\nsec")
+	assert.Equal(t, []string{"first"}, recorder.wait(t, 1))
+
+	// line is completed
+	write(path, "ond\n")
+	assert.Equal(t, []string{"first", "second"}, recorder.wait(t, 2))
+
+	// file is rotated, unfinished line of old file is read
+	write(path, "third")
+	os.Rename(path, path+".1")
+	write(path, "fourth\n")
+	assert.Equal(t, []string{"first", "second", "third", "fourth"}, recorder.wait(t, 4))
+
+	// file is truncated
+	os.Truncate(path, 0)
+	time.Sleep(10 * time.Millisecond)
+	write(path, "fifth\n")
+	assert.Equal(t, "fifth", recorder.wait(t, 5)[4])
+
+	close(stop)
+	assert.Nil(t, <-done)
+	f.close()
+}
@@ -0,0 +1,393 @@
+// Command heka-dump decodes framed protobuf messages sent to Heka, e.g.
+// by golog's Heka appender, and prints them as text or JSON lines.
+//
+// Usage:
+//
+//	heka-dump [flags] [capture ...]
+//
+// Messages are read from capture files, from stdin if no files are given
+// or file is "-", or from local TCP or UDP port if -listen is set. Examples:
+//
+//	heka-dump capture.bin
+//	heka-dump -listen 127.0.0.1:5565 -hmac myapp:1:secret
+//	heka-dump -proto udp -listen 127.0.0.1:5565 -json
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"net"
+	"os"
+	"os/signal"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/ildus/golog"
+	"github.com/ildus/golog/heka_emitter"
+	"github.com/ildus/golog/id"
+)
+
+func main() {
+	os.Exit(run(os.Args[1:], os.Stdin, os.Stdout, os.Stderr))
+}
+
+func run(args []string, stdin io.Reader, stdout, stderr io.Writer) int {
+	fs := flag.NewFlagSet("heka-dump", flag.ContinueOnError)
+	fs.SetOutput(stderr)
+	fs.Usage = func() {
+		fmt.Fprintln(stderr, "Usage: heka-dump [flags] [capture ...]")
+		fs.PrintDefaults()
+	}
+
+	var (
+		listen = fs.String("listen", "", "receive messages on local `address` instead of reading captures")
+		proto  = fs.String("proto", "tcp", "`network` to listen on: tcp or udp")
+		asJSON = fs.Bool("json", false, "print messages as JSON lines")
+		keys   hmacKeys
+	)
+	fs.Var(&keys, "hmac", "`signer:version:key` for verifying signed messages, can be repeated")
+
+	if err := fs.Parse(args); err != nil {
+		if err == flag.ErrHelp {
+			return 0
+		}
+		return 2
+	}
+
+	d := &dumper{out: stdout, errOut: stderr, json: *asJSON, keys: keys}
+
+	if *listen != "" {
+		stop := make(chan struct{})
+		signals := make(chan os.Signal, 1)
+		signal.Notify(signals, os.Interrupt)
+		defer signal.Stop(signals)
+
+		go func() {
+			<-signals
+			close(stop)
+		}()
+
+		ready := func(addr net.Addr) {
+			fmt.Fprintf(stderr, "Listening on %s %s\n", addr.Network(), addr)
+		}
+		if err := d.listen(*proto, *listen, ready, stop); err != nil {
+			fmt.Fprintln(stderr, "heka-dump:", err)
+			return 1
+		}
+		if d.errors > 0 {
+			return 1
+		}
+		return 0
+	}
+
+	files := fs.Args()
+	if len(files) == 0 {
+		files = []string{"-"}
+	}
+
+	failed := false
+	for _, path := range files {
+		if path == "-" {
+			d.dump(stdin)
+			continue
+		}
+
+		f, err := os.Open(path)
+		if err != nil {
+			fmt.Fprintln(stderr, "heka-dump:", err)
+			failed = true
+			continue
+		}
+		d.dump(f)
+		f.Close()
+	}
+
+	if failed || d.errors > 0 {
+		return 1
+	}
+	return 0
+}
+
+type hmacKey struct {
+	signer  string
+	version uint32
+	key     string
+}
+
+// hmacKeys collects repeated -hmac flags
+type hmacKeys []hmacKey
+
+func (keys *hmacKeys) String() string {
+	parts := make([]string, len(*keys))
+	for i, key := range *keys {
+		parts[i] = fmt.Sprintf("%s:%d:***", key.signer, key.version)
+	}
+	return strings.Join(parts, " ")
+}
+
+func (keys *hmacKeys) Set(s string) error {
+	parts := strings.SplitN(s, ":", 3)
+	if len(parts) != 3 {
+		return fmt.Errorf("expected signer:version:key")
+	}
+
+	version, err := strconv.ParseUint(parts[1], 10, 32)
+	if err != nil {
+		return fmt.Errorf("invalid key version %q", parts[1])
+	}
+
+	*keys = append(*keys, hmacKey{parts[0], uint32(version), parts[2]})
+	return nil
+}
+
+// dumper decodes streams and prints messages
+type dumper struct {
+	out    io.Writer
+	errOut io.Writer
+	json   bool
+	keys   []hmacKey
+
+	mu     sync.Mutex
+	errors int
+}
+
+// dump prints all messages of stream, frames which can't
+// be decoded are reported and skipped, read errors end it
+func (d *dumper) dump(r io.Reader) {
+	decoder := heka_emitter.NewDecoder(r)
+	for _, key := range d.keys {
+		decoder.AddHmacKey(key.signer, key.version, key.key)
+	}
+
+	for {
+		msg, err := decoder.Decode()
+		// connection closed by listener isn't decoding error
+		if err == io.EOF || errors.Is(err, net.ErrClosed) {
+			return
+		}
+
+		d.mu.Lock()
+		if err != nil {
+			d.errors++
+			fmt.Fprintln(d.errOut, "heka-dump:", err)
+		} else {
+			d.print(msg)
+		}
+		d.mu.Unlock()
+
+		// stream can't be read anymore
+		if decoder.Err() != nil {
+			return
+		}
+	}
+}
+
+// listen receives messages until stop is closed
+func (d *dumper) listen(network, addr string, ready func(net.Addr), stop <-chan struct{}) error {
+	switch network {
+	case "tcp":
+		ln, err := net.Listen(network, addr)
+		if err != nil {
+			return err
+		}
+		ready(ln.Addr())
+
+		go func() {
+			<-stop
+			ln.Close()
+		}()
+
+		var wg sync.WaitGroup
+		defer wg.Wait()
+
+		for {
+			conn, err := ln.Accept()
+			if err != nil {
+				select {
+				case <-stop:
+					return nil
+				default:
+					return err
+				}
+			}
+
+			wg.Add(1)
+			go func() {
+				defer wg.Done()
+				defer conn.Close()
+
+				// connection is closed on stop, but goroutine
+				// ends with connection handler anyway
+				done := make(chan struct{})
+				defer close(done)
+				go func() {
+					select {
+					case <-stop:
+						conn.Close()
+					case <-done:
+					}
+				}()
+				d.dump(conn)
+			}()
+		}
+	case "udp":
+		pc, err := net.ListenPacket(network, addr)
+		if err != nil {
+			return err
+		}
+		ready(pc.LocalAddr())
+
+		go func() {
+			<-stop
+			pc.Close()
+		}()
+
+		// every datagram carries one frame
+		buf := make([]byte, 1<<17)
+		for {
+			n, _, err := pc.ReadFrom(buf)
+			if err != nil {
+				select {
+				case <-stop:
+					return nil
+				default:
+					return err
+				}
+			}
+			d.dump(bytes.NewReader(buf[:n]))
+		}
+	}
+
+	return fmt.Errorf("Unsupported network %q", network)
+}
+
+func (d *dumper) print(msg *heka_emitter.Message) {
+	if d.json {
+		line, _ := json.Marshal(messageJSON(msg))
+		d.out.Write(append(line, '\n'))
+		return
+	}
+
+	buf := new(bytes.Buffer)
+	uuid, _ := id.Encode(msg.GetUuid())
+
+	fmt.Fprintf(buf, "uuid:        %s\n", uuid)
+	fmt.Fprintf(buf, "timestamp:   %s\n", timestamp(msg))
+	fmt.Fprintf(buf, "type:        %s\n", msg.GetType())
+	fmt.Fprintf(buf, "logger:      %s\n", msg.GetLogger())
+	fmt.Fprintf(buf, "severity:    %s (%d)\n", golog.LogLevel(msg.GetSeverity()), msg.GetSeverity())
+	fmt.Fprintf(buf, "hostname:    %s\n", msg.GetHostname())
+	fmt.Fprintf(buf, "pid:         %d\n", msg.GetPid())
+	fmt.Fprintf(buf, "env_version: %s\n", msg.GetEnvVersion())
+	fmt.Fprintf(buf, "payload:     %s\n", msg.GetPayload())
+
+	if len(msg.Fields) > 0 {
+		buf.WriteString("fields:\n")
+	}
+	for _, field := range msg.Fields {
+		kind := strings.ToLower(field.GetValueType().String())
+		if repr := field.GetRepresentation(); repr != "" {
+			kind += ", " + repr
+		}
+
+		var values []string
+		for _, value := range fieldValues(field) {
+			switch value.(type) {
+			case string, []byte:
+				values = append(values, fmt.Sprintf("%q", value))
+			default:
+				values = append(values, fmt.Sprint(value))
+			}
+		}
+
+		fmt.Fprintf(buf, "  %s [%s]: %s\n", field.GetName(), kind, strings.Join(values, ", "))
+	}
+
+	buf.WriteByte('\n')
+	d.out.Write(buf.Bytes())
+}
+
+func timestamp(msg *heka_emitter.Message) string {
+	return time.Unix(0, msg.GetTimestamp()).UTC().Format(time.RFC3339Nano)
+}
+
+func fieldValues(field *heka_emitter.Field) []interface{} {
+	var values []interface{}
+	switch field.GetValueType() {
+	case heka_emitter.Field_STRING:
+		for _, v := range field.ValueString {
+			values = append(values, v)
+		}
+	case heka_emitter.Field_BYTES:
+		for _, v := range field.ValueBytes {
+			values = append(values, v)
+		}
+	case heka_emitter.Field_INTEGER:
+		for _, v := range field.ValueInteger {
+			values = append(values, v)
+		}
+	case heka_emitter.Field_DOUBLE:
+		for _, v := range field.ValueDouble {
+			values = append(values, v)
+		}
+	case heka_emitter.Field_BOOL:
+		for _, v := range field.ValueBool {
+			values = append(values, v)
+		}
+	}
+	return values
+}
+
+type jsonMessage struct {
+	Uuid         string      `json:"uuid"`
+	Timestamp    string      `json:"timestamp"`
+	Type         string      `json:"type"`
+	Logger       string      `json:"logger"`
+	Severity     int32       `json:"severity"`
+	SeverityName string      `json:"severity_name"`
+	Payload      string      `json:"payload"`
+	EnvVersion   string      `json:"env_version"`
+	Pid          int32       `json:"pid"`
+	Hostname     string      `json:"hostname"`
+	Fields       []jsonField `json:"fields"`
+}
+
+type jsonField struct {
+	Name           string        `json:"name"`
+	Type           string        `json:"type"`
+	Representation string        `json:"representation,omitempty"`
+	Values         []interface{} `json:"values"`
+}
+
+func messageJSON(msg *heka_emitter.Message) *jsonMessage {
+	uuid, _ := id.Encode(msg.GetUuid())
+	m := &jsonMessage{
+		Uuid:         uuid,
+		Timestamp:    timestamp(msg),
+		Type:         msg.GetType(),
+		Logger:       msg.GetLogger(),
+		Severity:     msg.GetSeverity(),
+		SeverityName: golog.LogLevel(msg.GetSeverity()).String(),
+		Payload:      msg.GetPayload(),
+		EnvVersion:   msg.GetEnvVersion(),
+		Pid:          msg.GetPid(),
+		Hostname:     msg.GetHostname(),
+		Fields:       []jsonField{},
+	}
+
+	for _, field := range msg.Fields {
+		m.Fields = append(m.Fields, jsonField{
+			Name:           field.GetName(),
+			Type:           strings.ToLower(field.GetValueType().String()),
+			Representation: field.GetRepresentation(),
+			Values:         fieldValues(field),
+		})
+	}
+	return m
+}
@@ -0,0 +1,279 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"io"
+	"io/ioutil"
+	"net"
+	"os"
+	"runtime"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/ildus/golog/heka_emitter"
+	"github.com/ildus/golog/logtest"
+	"github.com/stretchr/testify/assert"
+)
+
+func emitFrames(t *testing.T, w *bytes.Buffer, hmacKey string) {
+	env := logtest.NewEnvironment()
+	// d1c7c768-b1be-4c70-93a6-9b52910d4baa
+	env.ID = []byte{0xd1, 0xc7, 0xc7, 0x68, 0xb1, 0xbe, 0x4c, 0x70, 0x93,
+		0xa6, 0x9b, 0x52, 0x91, 0x0d, 0x4b, 0xaa}
+
+	pe := heka_emitter.NewProtobufEmitter(w, "1", "web-1", "payments")
+	pe.Environment = env
+	if hmacKey != "" {
+		pe.ConfigureHmac(map[string]string{
+			"hmac_signer":      "golog",
+			"hmac_key_version": "2",
+			"hmac_key":         hmacKey,
+		})
+	}
+
+	amount, _ := heka_emitter.NewField("amount", 10, "count")
+	tags, _ := heka_emitter.NewField("tag", "vip", "")
+	if err := pe.Emit(5, "golog", "charged", []*heka_emitter.Field{amount, tags}); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestDump(t *testing.T) {
+	capture := new(bytes.Buffer)
+	emitFrames(t, capture, "")
+
+	stdout, stderr := new(bytes.Buffer), new(bytes.Buffer)
+	code := run(nil, capture, stdout, stderr)
+	assert.Equal(t, 0, code)
+	assert.Empty(t, stderr.String())
+
+	assert.Equal(t, `uuid:        d1c7c768-b1be-4c70-93a6-9b52910d4baa
+timestamp:   2009-11-10T23:00:00Z
+type:        golog
+logger:      payments
+severity:    NOTICE (5)
+hostname:    web-1
+pid:         1234
+env_version: 1
+payload:     charged
+fields:
+  amount [integer, count]: 10
+  tag [string]: "vip"
+
+`, stdout.String())
+}
+
+func TestDumpJSON(t *testing.T) {
+	capture := new(bytes.Buffer)
+	emitFrames(t, capture, "")
+	emitFrames(t, capture, "")
+
+	stdout := new(bytes.Buffer)
+	code := run([]string{"-json"}, capture, stdout, new(bytes.Buffer))
+	assert.Equal(t, 0, code)
+
+	lines := strings.Split(strings.TrimSpace(stdout.String()), "\n")
+	assert.Len(t, lines, 2)
+
+	msg := map[string]interface{}{}
+	assert.Nil(t, json.Unmarshal([]byte(lines[0]), &msg))
+	assert.Equal(t, "d1c7c768-b1be-4c70-93a6-9b52910d4baa", msg["uuid"])
+	assert.Equal(t, "NOTICE", msg["severity_name"])
+	assert.Equal(t, "charged", msg["payload"])
+	assert.Equal(t, []interface{}{
+		map[string]interface{}{"name": "amount", "type": "integer", "representation": "count",
+			"values": []interface{}{float64(10)}},
+		map[string]interface{}{"name": "tag", "type": "string", "values": []interface{}{"vip"}},
+	}, msg["fields"])
+}
+
+func TestDumpHmac(t *testing.T) {
+	capture := new(bytes.Buffer)
+	emitFrames(t, capture, "secret")
+	frame := capture.Bytes()
+
+	stdout, stderr := new(bytes.Buffer), new(bytes.Buffer)
+	code := run(nil, bytes.NewReader(frame), stdout, stderr)
+	assert.Equal(t, 1, code)
+	assert.Contains(t, stderr.String(), heka_emitter.ErrUnknownSigner.Error())
+
+	stdout, stderr = new(bytes.Buffer), new(bytes.Buffer)
+	code = run([]string{"-hmac", "golog:2:secret"}, bytes.NewReader(frame), stdout, stderr)
+	assert.Equal(t, 0, code)
+	assert.Contains(t, stdout.String(), "charged")
+
+	code = run([]string{"-hmac", "golog:secret"}, bytes.NewReader(frame), stdout, stderr)
+	assert.Equal(t, 2, code)
+}
+
+// reader which fails on every read
+type failingReader struct{}
+
+func (failingReader) Read(p []byte) (int, error) {
+	return 0, errors.New("broken")
+}
+
+func TestDumpReadError(t *testing.T) {
+	for _, c := range []struct {
+		args  []string
+		stdin io.Reader
+	}{
+		{nil, failingReader{}},
+		{[]string{t.TempDir()}, nil},
+	} {
+		stderr := new(bytes.Buffer)
+		status := make(chan int, 1)
+		go func() {
+			status <- run(c.args, c.stdin, ioutil.Discard, stderr)
+		}()
+
+		select {
+		case code := <-status:
+			assert.Equal(t, 1, code)
+			// error is reported once and reading stops
+			assert.Equal(t, 1, strings.Count(stderr.String(), "heka-dump:"), stderr.String())
+		case <-time.After(5 * time.Second):
+			t.Fatalf("dump of %v doesn't end", c.args)
+		}
+	}
+}
+
+// collects output written from several goroutines
+type syncBuffer struct {
+	mu  sync.Mutex
+	buf bytes.Buffer
+}
+
+func (b *syncBuffer) Write(p []byte) (int, error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return b.buf.Write(p)
+}
+
+func (b *syncBuffer) String() string {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return b.buf.String()
+}
+
+func TestListen(t *testing.T) {
+	for _, network := range []string{"tcp", "udp"} {
+		out := &syncBuffer{}
+		d := &dumper{out: out, errOut: out, json: true}
+
+		addrs := make(chan net.Addr, 1)
+		stop := make(chan struct{})
+		done := make(chan error)
+		go func() {
+			done <- d.listen(network, "127.0.0.1:0", func(addr net.Addr) { addrs <- addr }, stop)
+		}()
+
+		addr := <-addrs
+		conn, err := net.Dial(network, addr.String())
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		capture := new(bytes.Buffer)
+		emitFrames(t, capture, "")
+		conn.Write(capture.Bytes())
+		conn.Close()
+
+		deadline := time.Now().Add(5 * time.Second)
+		for !strings.Contains(out.String(), "charged") && time.Now().Before(deadline) {
+			time.Sleep(time.Millisecond)
+		}
+		assert.Contains(t, out.String(), `"payload":"charged"`, network)
+
+		close(stop)
+		assert.Nil(t, <-done)
+	}
+}
+
+func TestListenConnections(t *testing.T) {
+	out := &syncBuffer{}
+	d := &dumper{out: out, errOut: out, json: true}
+
+	addrs := make(chan net.Addr, 1)
+	stop := make(chan struct{})
+	done := make(chan error)
+	go func() {
+		done <- d.listen("tcp", "127.0.0.1:0", func(addr net.Addr) { addrs <- addr }, stop)
+	}()
+	addr := <-addrs
+
+	dial := func() {
+		conn, err := net.Dial("tcp", addr.String())
+		if err != nil {
+			t.Fatal(err)
+		}
+		conn.Close()
+	}
+
+	dial()
+	time.Sleep(50 * time.Millisecond)
+	before := runtime.NumGoroutine()
+
+	// goroutines of closed connections end
+	for i := 0; i < 20; i++ {
+		dial()
+	}
+	deadline := time.Now().Add(5 * time.Second)
+	for runtime.NumGoroutine() > before && time.Now().Before(deadline) {
+		time.Sleep(time.Millisecond)
+	}
+	assert.True(t, runtime.NumGoroutine() <= before)
+
+	// open connection closed on stop isn't error
+	conn, err := net.Dial("tcp", addr.String())
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer conn.Close()
+	time.Sleep(50 * time.Millisecond)
+
+	close(stop)
+	assert.Nil(t, <-done)
+	assert.Equal(t, 0, d.errors)
+}
+
+func TestListenStatus(t *testing.T) {
+	stderr := &syncBuffer{}
+	status := make(chan int)
+	go func() {
+		status <- run([]string{"-listen", "127.0.0.1:0"}, nil, ioutil.Discard, stderr)
+	}()
+
+	var addr string
+	deadline := time.Now().Add(5 * time.Second)
+	for addr == "" && time.Now().Before(deadline) {
+		if line := stderr.String(); strings.HasPrefix(line, "Listening on tcp ") {
+			addr = strings.TrimSpace(strings.TrimPrefix(line, "Listening on tcp "))
+		}
+		time.Sleep(time.Millisecond)
+	}
+
+	conn, err := net.Dial("tcp", addr)
+	if err != nil {
+		t.Fatal(err)
+	}
+	// frame with broken header
+	conn.Write([]byte{heka_emitter.RECORD_SEPARATOR, 2, 0xff, 0xff, heka_emitter.UNIT_SEPARATOR})
+	conn.Close()
+
+	for !strings.Contains(stderr.String(), "heka-dump:") && time.Now().Before(deadline) {
+		time.Sleep(time.Millisecond)
+	}
+
+	p, err := os.FindProcess(os.Getpid())
+	if err != nil {
+		t.Fatal(err)
+	}
+	p.Signal(os.Interrupt)
+
+	assert.Equal(t, 1, <-status)
+}
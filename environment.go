@@ -0,0 +1,51 @@
+package golog
+
+import (
+	"os"
+	"time"
+
+	"github.com/ildus/golog/id"
+)
+
+// Environment supplies values which loggers and emitters take from
+// system: current time, id of process, IDs of logs, and exiting
+// after fatal logs. Custom environment can make output deterministic,
+// e.g. in tests.
+type Environment interface {
+	// current time
+	Now() time.Time
+
+	// id of current process
+	Pid() int
+
+	// new unique ID, 16 bytes long UUID
+	NewID() ([]byte, error)
+
+	// exits process with given status code
+	Exit(code int)
+}
+
+// Environment of real system, it is used if logger has no environment.
+// IDs are time-ordered UUIDs (look at id.GenerateOrdered).
+var SystemEnvironment Environment = systemEnvironment{}
+
+type systemEnvironment struct{}
+
+// process id doesn't change, so it is read once
+var pid = os.Getpid()
+
+func (systemEnvironment) Now() time.Time {
+	return time.Now()
+}
+
+func (systemEnvironment) Pid() int {
+	return pid
+}
+
+func (systemEnvironment) NewID() ([]byte, error) {
+	return id.GenerateOrderedBytes()
+}
+
+func (systemEnvironment) Exit(code int) {
+	os.Exit(code)
+}
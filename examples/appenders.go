@@ -7,13 +7,19 @@ func mongo() {
 	logger := golog.Default
 
 	// make instance of mongo appender and enable it
-	logger.Enable(appenders.Mongo(golog.Conf{
+	appender, err := appenders.Mongo(golog.Conf{
 		"host":       "127.0.0.1:27017",
 		"db":         "somedb",
 		"collection": "logs",
 		"username":   "myusername",
 		"password":   "mypassword",
-	}))
+	})
+	if err != nil {
+		panic(err)
+	}
+	defer appender.Close()
+
+	logger.Enable(appender)
 
 	logger.Debug("some message")
 }
@@ -12,10 +12,12 @@ var (
 func init() {
 	loggers = map[string]*Logger{}
 	Default = GetLogger("default")
+	applyLevelEnv()
 }
 
 // Function for getting logger instance.
-// Method returns singleton logger instance.
+// Method returns singleton logger instance. Level of new logger
+// is set by applied level spec (look at ApplyLevelSpec).
 func GetLogger(name string) *Logger {
 	logger, ok := loggers[name]
 	if !ok {
@@ -25,14 +27,7 @@ func GetLogger(name string) *Logger {
 		}
 
 		logger.Enable(StdoutAppender())
-		logger.normalizeName()
-
-		// recalculate names
-		curnamelen = len(logger.Name)
-		for _, _logger := range loggers {
-			_logger.normalizeName()
-		}
-
+		applyLevelRules(logger)
 		loggers[name] = logger
 	}
 
@@ -50,7 +45,8 @@ func Disable(name string) {
 	logger.disabled = true
 }
 
-// Will enable all logs comming to logger with provided name
+// Will enable all logs comming to logger with provided name,
+// also if it was disabled by level spec
 func Enable(name string) {
 	logger := loggers[name]
 	if logger == nil {
@@ -59,4 +55,5 @@ func Enable(name string) {
 	}
 
 	logger.disabled = false
+	logger.specOff = false
 }
@@ -68,3 +68,136 @@ func TestDisableLogger(t *testing.T) {
 
 	Enable("some-unknown-name")
 }
+
+func TestApplyLevelSpec(t *testing.T) {
+	defer cleanupTest()
+
+	payments := GetLogger("payments")
+	lib := GetLogger("github.com/someuser/somelib")
+	other := GetLogger("other")
+
+	err := ApplyLevelSpec("info, payments=debug, github.com/someuser/*=error")
+	assert.Nil(t, err)
+
+	assert.Equal(t, DEBUG, payments.Level)
+	assert.Equal(t, ERROR, lib.Level)
+	assert.Equal(t, INFO, other.Level)
+
+	// loggers created later get levels too
+	assert.Equal(t, ERROR, GetLogger("github.com/someuser/another").Level)
+	assert.Equal(t, INFO, GetLogger("new").Level)
+}
+
+func TestApplyLevelSpecSpecific(t *testing.T) {
+	defer cleanupTest()
+
+	err := ApplyLevelSpec("github.com/*=warning,github.com/someuser/*=error,github.com/someuser/lib=notice,*=critical")
+	assert.Nil(t, err)
+
+	assert.Equal(t, NOTICE, GetLogger("github.com/someuser/lib").Level)
+	assert.Equal(t, ERROR, GetLogger("github.com/someuser/other").Level)
+	assert.Equal(t, WARNING, GetLogger("github.com/other").Level)
+	assert.Equal(t, CRITICAL, GetLogger("main").Level)
+
+	// last of equally specific rules wins
+	assert.Nil(t, ApplyLevelSpec("main=info,main=error"))
+	assert.Equal(t, ERROR, GetLogger("main").Level)
+}
+
+func TestApplyLevelSpecOff(t *testing.T) {
+	defer cleanupTest()
+
+	ta := &testAppender{}
+	logger := GetLogger("noisy")
+	logger.Enable(ta)
+
+	assert.Nil(t, ApplyLevelSpec("noisy=off"))
+	logger.Error("some msg")
+	assert.Exactly(t, 0, ta.count)
+
+	assert.Nil(t, ApplyLevelSpec("noisy=error"))
+	logger.Error("some msg")
+	logger.Warn("some msg")
+	assert.Exactly(t, 1, ta.count)
+
+	// logger disabled by spec can be enabled manually
+	assert.Nil(t, ApplyLevelSpec("noisy=off"))
+	Enable("noisy")
+	logger.Error("some msg")
+	assert.Exactly(t, 2, ta.count)
+}
+
+func TestApplyLevelSpecReplaced(t *testing.T) {
+	defer cleanupTest()
+
+	ta := &testAppender{}
+	noisy := GetLogger("noisy")
+	noisy.Level = WARNING
+	noisy.Enable(ta)
+	other := GetLogger("other")
+
+	assert.Nil(t, ApplyLevelSpec("noisy=off"))
+	assert.Nil(t, ApplyLevelSpec("other=debug"))
+	noisy.Error("some msg")
+	assert.Exactly(t, 1, ta.count)
+	assert.Equal(t, WARNING, noisy.Level)
+
+	assert.Nil(t, ApplyLevelSpec("noisy=error,other=error"))
+	assert.Nil(t, ApplyLevelSpec("other=info"))
+	assert.Equal(t, WARNING, noisy.Level)
+	assert.Equal(t, INFO, other.Level)
+
+	assert.Nil(t, ApplyLevelSpec(""))
+	assert.Equal(t, DEBUG, other.Level)
+}
+
+func TestApplyLevelSpecKeepsDisabled(t *testing.T) {
+	defer cleanupTest()
+
+	ta := &testAppender{}
+	logger := GetLogger("payments")
+	logger.Enable(ta)
+
+	Disable("payments")
+	assert.Nil(t, ApplyLevelSpec("info"))
+	assert.Nil(t, ApplyLevelSpec("payments=debug"))
+	logger.Error("some msg")
+	assert.Exactly(t, 0, ta.count)
+	assert.Equal(t, DEBUG, logger.Level)
+
+	Enable("payments")
+	logger.Error("some msg")
+	assert.Exactly(t, 1, ta.count)
+}
+
+func TestApplyLevelSpecInvalid(t *testing.T) {
+	defer cleanupTest()
+
+	logger := GetLogger("payments")
+	assert.Nil(t, ApplyLevelSpec("payments=info"))
+
+	for _, spec := range []string{"verbose", "payments=debug,=info", "payments=loud", "a/*/b=info", "*a=info"} {
+		assert.NotNil(t, ApplyLevelSpec(spec), spec)
+		assert.Equal(t, INFO, logger.Level, spec)
+	}
+
+	// empty spec removes rules
+	assert.Nil(t, ApplyLevelSpec(""))
+	assert.Equal(t, DEBUG, GetLogger("new").Level)
+}
+
+func TestLevelEnv(t *testing.T) {
+	defer cleanupTest()
+
+	t.Setenv(LevelEnv, "warning,payments=debug")
+	applyLevelEnv()
+
+	assert.Equal(t, WARNING, GetLogger("main").Level)
+	assert.Equal(t, DEBUG, GetLogger("payments").Level)
+	assert.Nil(t, LevelEnvError())
+
+	t.Setenv(LevelEnv, "payments=loud")
+	applyLevelEnv()
+	assert.NotNil(t, LevelEnvError())
+	assert.Equal(t, DEBUG, GetLogger("payments").Level)
+}
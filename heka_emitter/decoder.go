@@ -0,0 +1,176 @@
+/* This Source Code Form is subject to the terms of the Mozilla Public
+ * License, v. 2.0. If a copy of the MPL was not distributed with this
+ * file, You can obtain one at http://mozilla.org/MPL/2.0/. */
+
+package heka_emitter
+
+import (
+	"bufio"
+	"crypto/hmac"
+	"errors"
+	"fmt"
+	"io"
+)
+
+var (
+	ErrUnknownSigner = errors.New("Unknown HMAC signer")
+	ErrInvalidHmac   = errors.New("Invalid message HMAC")
+)
+
+// maximum length of one frame
+const maxFrameSize = HEADER_DELIMITER_SIZE + MAX_HEADER_SIZE + 1 + MAX_MESSAGE_SIZE
+
+// A Decoder reads framed, Protobuf-encoded messages from a stream.
+// If a frame is corrupted, its HMAC doesn't match or message can't be
+// unmarshalled, Decode returns an error and the next call continues from
+// the next record separator, so decoding can go on. Errors of underlying
+// reader, including end of stream inside a frame, are final: Decode
+// returns the same error from then on and Err reports it.
+type Decoder struct {
+	r    *bufio.Reader
+	keys map[string][]byte
+	err  error // error of reader, decoding stops after it
+}
+
+// NewDecoder creates decoder reading frames from r.
+func NewDecoder(r io.Reader) *Decoder {
+	return &Decoder{
+		r:    bufio.NewReaderSize(r, maxFrameSize),
+		keys: map[string][]byte{},
+	}
+}
+
+// AddHmacKey adds key which is used for verifying messages signed by
+// signer with given key version. Signed messages with unknown signer
+// are rejected.
+func (d *Decoder) AddHmacKey(signer string, keyVersion uint32, key string) {
+	d.keys[hmacKeyName(signer, keyVersion)] = []byte(key)
+}
+
+// Decode reads next message. It returns io.EOF when stream ends. If Err
+// returns nil, other errors mean that a frame was skipped and Decode can
+// be called again.
+func (d *Decoder) Decode() (*Message, error) {
+	if d.err != nil {
+		return nil, d.err
+	}
+
+	// look for beginning of a frame, anything before it is garbage
+	for {
+		b, err := d.r.Peek(1)
+		if err != nil {
+			d.err = err
+			return nil, err
+		}
+		if b[0] == RECORD_SEPARATOR {
+			break
+		}
+		d.r.Discard(1)
+	}
+
+	msg, size, err := d.decodeFrame()
+	if d.err != nil {
+		return nil, d.err
+	}
+	if err != nil {
+		// if framing is broken, only record separator is skipped and
+		// next frame is searched right after it, otherwise message
+		// bytes can contain record separator, so whole frame is skipped
+		if size == 0 {
+			size = 1
+		}
+		d.r.Discard(size)
+		return nil, err
+	}
+
+	d.r.Discard(size)
+	return msg, nil
+}
+
+// Err returns error of underlying reader which stopped decoding.
+// It returns nil if stream ended with io.EOF.
+func (d *Decoder) Err() error {
+	if d.err == io.EOF {
+		return nil
+	}
+	return d.err
+}
+
+func (d *Decoder) decodeFrame() (*Message, int, error) {
+	frame, err := d.peek(HEADER_DELIMITER_SIZE)
+	if err != nil {
+		return nil, 0, err
+	}
+
+	headerSize := int(frame[1])
+	if headerSize == 0 {
+		return nil, 0, fmt.Errorf("Empty message header")
+	}
+
+	if frame, err = d.peek(HEADER_FRAMING_SIZE + headerSize); err != nil {
+		return nil, 0, err
+	}
+	if frame[HEADER_DELIMITER_SIZE+headerSize] != UNIT_SEPARATOR {
+		return nil, 0, fmt.Errorf("Missing unit separator after message header")
+	}
+
+	header := new(Header)
+	if err = header.Unmarshal(frame[HEADER_DELIMITER_SIZE : HEADER_DELIMITER_SIZE+headerSize]); err != nil {
+		return nil, 0, fmt.Errorf("Error decoding message header: %s", err)
+	}
+
+	msgSize := int(header.GetMessageLength())
+	if msgSize > MAX_MESSAGE_SIZE {
+		return nil, 0, fmt.Errorf("Message size %d exceeds maximum size %d",
+			msgSize, MAX_MESSAGE_SIZE)
+	}
+
+	frameSize := HEADER_FRAMING_SIZE + headerSize + msgSize
+	if frame, err = d.peek(frameSize); err != nil {
+		return nil, 0, err
+	}
+
+	msgBytes := frame[HEADER_FRAMING_SIZE+headerSize:]
+	if header.Hmac != nil {
+		if err = d.verify(header, msgBytes); err != nil {
+			return nil, frameSize, err
+		}
+	}
+
+	msg := new(Message)
+	if err = msg.Unmarshal(msgBytes); err != nil {
+		return nil, frameSize, fmt.Errorf("Error decoding message: %s", err)
+	}
+
+	return msg, frameSize, nil
+}
+
+// peek returns next n bytes without consuming them,
+// error of reader is kept, so it isn't retried
+func (d *Decoder) peek(n int) ([]byte, error) {
+	b, err := d.r.Peek(n)
+	if err == io.EOF {
+		err = io.ErrUnexpectedEOF
+	}
+	if err != nil {
+		d.err = err
+	}
+	return b, err
+}
+
+func (d *Decoder) verify(header *Header, msg []byte) error {
+	key, ok := d.keys[hmacKeyName(header.GetHmacSigner(), header.GetHmacKeyVersion())]
+	if !ok {
+		return ErrUnknownSigner
+	}
+
+	if !hmac.Equal(header.Hmac, hmacSum(header.GetHmacHashFunction(), key, msg)) {
+		return ErrInvalidHmac
+	}
+	return nil
+}
+
+// hekad looks up keys by signer name and key version
+func hmacKeyName(signer string, keyVersion uint32) string {
+	return fmt.Sprintf("%s_%d", signer, keyVersion)
+}
@@ -0,0 +1,215 @@
+package heka_emitter
+
+import (
+	"bytes"
+	"errors"
+	"io"
+	"testing"
+	"time"
+
+	"github.com/ildus/golog"
+	"github.com/ildus/golog/id"
+	"github.com/ildus/golog/logtest"
+	"github.com/stretchr/testify/assert"
+)
+
+func emitFrames(t *testing.T, pe *ProtobufEmitter, payloads ...string) {
+	for _, payload := range payloads {
+		field, _ := NewField("number", len(payload), "count")
+		if err := pe.Emit(6, "test", payload, []*Field{field}); err != nil {
+			t.Fatal(err)
+		}
+	}
+}
+
+func newTestEmitter(buf *bytes.Buffer) *ProtobufEmitter {
+	pe := NewProtobufEmitter(buf, "2", "example.com", "test")
+	pe.Environment = logtest.NewEnvironment()
+	return pe
+}
+
+func TestDecode(t *testing.T) {
+	buf := new(bytes.Buffer)
+	emitFrames(t, newTestEmitter(buf), "first", "second")
+
+	decoder := NewDecoder(buf)
+	msg, err := decoder.Decode()
+	assert.Nil(t, err)
+	assert.Equal(t, "first", msg.GetPayload())
+	assert.Equal(t, "test", msg.GetLogger())
+	assert.Equal(t, "example.com", msg.GetHostname())
+	assert.Equal(t, int32(6), msg.GetSeverity())
+	assert.Equal(t, int32(1234), msg.GetPid())
+	assert.Equal(t, int64(1257894000000000000), msg.GetTimestamp())
+	assert.Equal(t, "number", msg.Fields[0].GetName())
+	assert.Equal(t, []int64{5}, msg.Fields[0].GetValueInteger())
+
+	msg, err = decoder.Decode()
+	assert.Nil(t, err)
+	assert.Equal(t, "second", msg.GetPayload())
+
+	_, err = decoder.Decode()
+	assert.Equal(t, io.EOF, err)
+}
+
+func TestDecodeResync(t *testing.T) {
+	good := new(bytes.Buffer)
+	emitFrames(t, newTestEmitter(good), "good")
+	frame := good.Bytes()
+
+	stream := new(bytes.Buffer)
+	// garbage before frame
+	stream.Write([]byte("garbage"))
+	stream.Write(frame)
+	// frame without unit separator
+	stream.Write([]byte{RECORD_SEPARATOR, 2, 0x8, 0x75, 0x0})
+	stream.Write(frame)
+	// message has invalid wire type
+	corrupted := append([]byte(nil), frame...)
+	corrupted[HEADER_FRAMING_SIZE+int(frame[1])] = 0x0f
+	stream.Write(corrupted)
+	stream.Write(frame)
+	// corrupted message contains record separator
+	inner := new(bytes.Buffer)
+	emitFrames(t, newTestEmitter(inner), "a\x1eb")
+	corrupted = inner.Bytes()
+	corrupted[HEADER_FRAMING_SIZE+int(corrupted[1])] = 0x0f
+	stream.Write(corrupted)
+	stream.Write(frame)
+	// header claims too big message
+	stream.Write([]byte{RECORD_SEPARATOR, 4, 0x8, 0x80, 0x80, 0x8, UNIT_SEPARATOR})
+	stream.Write(frame)
+	// frame is cut by end of stream
+	stream.Write(frame[:len(frame)-1])
+
+	decoder := NewDecoder(stream)
+	var (
+		payloads []string
+		errors   int
+	)
+
+	for {
+		msg, err := decoder.Decode()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			errors++
+			if decoder.Err() != nil {
+				break
+			}
+			continue
+		}
+		payloads = append(payloads, msg.GetPayload())
+	}
+
+	assert.Equal(t, []string{"good", "good", "good", "good", "good"}, payloads)
+	assert.Equal(t, 5, errors)
+	assert.Equal(t, io.ErrUnexpectedEOF, decoder.Err())
+}
+
+// reader which fails on every read
+type failingReader struct{ reads int }
+
+func (r *failingReader) Read(p []byte) (int, error) {
+	r.reads++
+	return 0, errBroken
+}
+
+var errBroken = errors.New("broken")
+
+func TestDecodeReaderError(t *testing.T) {
+	r := &failingReader{}
+	decoder := NewDecoder(r)
+
+	for i := 0; i < 3; i++ {
+		_, err := decoder.Decode()
+		assert.Equal(t, errBroken, err)
+		assert.Equal(t, errBroken, decoder.Err())
+	}
+	// reader isn't asked again after failure
+	assert.Equal(t, 1, r.reads)
+
+	// end of stream isn't error
+	decoder = NewDecoder(new(bytes.Buffer))
+	_, err := decoder.Decode()
+	assert.Equal(t, io.EOF, err)
+	assert.Nil(t, decoder.Err())
+}
+
+func TestDecodeHmac(t *testing.T) {
+	buf := new(bytes.Buffer)
+	pe := newTestEmitter(buf)
+	pe.ConfigureHmac(map[string]string{
+		"hmac_signer":      "golog",
+		"hmac_key_version": "1",
+		"hmac_key":         "secret",
+		"hmac_hash":        "sha1",
+	})
+	emitFrames(t, pe, "signed")
+	frame := append([]byte(nil), buf.Bytes()...)
+
+	// signer is unknown
+	_, err := NewDecoder(bytes.NewReader(frame)).Decode()
+	assert.Equal(t, ErrUnknownSigner, err)
+
+	decoder := NewDecoder(bytes.NewReader(frame))
+	decoder.AddHmacKey("golog", 1, "secret")
+	msg, err := decoder.Decode()
+	assert.Nil(t, err)
+	assert.Equal(t, "signed", msg.GetPayload())
+
+	decoder = NewDecoder(bytes.NewReader(frame))
+	decoder.AddHmacKey("golog", 1, "other")
+	_, err = decoder.Decode()
+	assert.Equal(t, ErrInvalidHmac, err)
+
+	// payload is changed after signing
+	frame[bytes.Index(frame, []byte("signed"))] = 'S'
+	decoder = NewDecoder(bytes.NewReader(frame))
+	decoder.AddHmacKey("golog", 1, "secret")
+	_, err = decoder.Decode()
+	assert.Equal(t, ErrInvalidHmac, err)
+}
+
+func TestEmitLog(t *testing.T) {
+	buf := new(bytes.Buffer)
+	pe := newTestEmitter(buf)
+
+	logger := &golog.Logger{Name: "payments"}
+	logID, _ := id.GenerateOrderedBytes()
+	err := pe.EmitLog("test", golog.Log{
+		ID:      logID,
+		Time:    time.Unix(1257894000, 5),
+		Message: "charged",
+		Level:   golog.NOTICE,
+		Data:    []interface{}{map[string]interface{}{"amount": 10}},
+		Pid:     42,
+		Logger:  logger,
+	})
+	assert.Nil(t, err)
+
+	// without logger emitter's name is used
+	assert.Nil(t, pe.EmitLog("test", golog.Log{Message: "anonymous"}))
+
+	decoder := NewDecoder(buf)
+	msg, err := decoder.Decode()
+	assert.Nil(t, err)
+	assert.Equal(t, logID, msg.GetUuid())
+	assert.Equal(t, "charged", msg.GetPayload())
+	assert.Equal(t, "payments", msg.GetLogger())
+	assert.Equal(t, "test", msg.GetType())
+	assert.Equal(t, int64(1257894000000000005), msg.GetTimestamp())
+	assert.Equal(t, int32(42), msg.GetPid())
+	assert.Equal(t, int32(golog.NOTICE), msg.GetSeverity())
+	assert.Equal(t, "example.com", msg.GetHostname())
+	assert.Equal(t, "amount", msg.Fields[0].GetName())
+	assert.Equal(t, []int64{10}, msg.Fields[0].GetValueInteger())
+
+	msg, err = decoder.Decode()
+	assert.Nil(t, err)
+	assert.Equal(t, "anonymous", msg.GetPayload())
+	assert.Equal(t, "test", msg.GetLogger())
+	// log without ID gets generated one
+	assert.Len(t, msg.GetUuid(), 16)
+}
@@ -5,14 +5,21 @@
 package heka_emitter
 
 import (
+	"crypto/hmac"
+	"crypto/md5"
+	"crypto/sha1"
+	"errors"
 	"fmt"
+	"hash"
 	"io"
-	"os"
+	"strconv"
+	"strings"
 	"sync"
+	"sync/atomic"
 	"time"
 
 	"github.com/gogo/protobuf/proto"
-	"github.com/ildus/golog/id"
+	"github.com/ildus/golog"
 )
 
 // TryClose closes w if w implements io.Closer.
@@ -41,6 +48,7 @@ type hekaMessage struct {
 	msg      *Message
 	buf      *proto.Buffer
 	outBytes []byte
+	msgBytes []byte
 }
 
 func (hm *hekaMessage) free() {
@@ -49,17 +57,61 @@ func (hm *hekaMessage) free() {
 	}
 	hm.buf.Reset()
 	hm.outBytes = hm.outBytes[:0]
+	hm.msgBytes = hm.msgBytes[:0]
 	hm.msg.Fields = nil
+	hm.header.Reset()
 	hekaMessagePool.Put(hm)
 }
 
-func (hm *hekaMessage) marshalFrame() ([]byte, error) {
+// hmacSigner signs messages the same way as hekad verifies them:
+// HMAC of encoded message is computed with key which hekad finds
+// by signer name and key version.
+type hmacSigner struct {
+	name     string
+	version  uint32
+	key      []byte
+	hashFunc Header_HmacHashFunction
+}
+
+func (s *hmacSigner) sign(header *Header, msg []byte) {
+	header.SetHmac(s.hashFunc, s.name, s.version, hmacSum(s.hashFunc, s.key, msg))
+}
+
+func hmacSum(hashFunc Header_HmacHashFunction, key, msg []byte) []byte {
+	var h func() hash.Hash
+	switch hashFunc {
+	case Header_SHA1:
+		h = sha1.New
+	default:
+		h = md5.New
+	}
+
+	mac := hmac.New(h, key)
+	mac.Write(msg)
+	return mac.Sum(nil)
+}
+
+func (hm *hekaMessage) marshalFrame(signer *hmacSigner) ([]byte, error) {
 	msgSize := hm.msg.Size()
 	if msgSize > MAX_MESSAGE_SIZE {
 		return nil, fmt.Errorf("Message size %d exceeds maximum size %d",
 			msgSize, MAX_MESSAGE_SIZE)
 	}
 	hm.header.SetMessageLength(uint32(msgSize))
+
+	// hmac is stored in header, so message should be encoded before it
+	if signer != nil {
+		if cap(hm.msgBytes) < msgSize {
+			hm.msgBytes = make([]byte, msgSize)
+		} else {
+			hm.msgBytes = hm.msgBytes[:msgSize]
+		}
+		if _, err := hm.msg.MarshalTo(hm.msgBytes); err != nil {
+			return nil, err
+		}
+		signer.sign(hm.header, hm.msgBytes)
+	}
+
 	headerSize := hm.header.Size()
 	if headerSize > MAX_HEADER_SIZE {
 		return nil, fmt.Errorf("Header size %d exceeds maximum size %d",
@@ -78,6 +130,10 @@ func (hm *hekaMessage) marshalFrame() ([]byte, error) {
 		return nil, err
 	}
 	hm.outBytes[headerSize+HEADER_DELIMITER_SIZE] = UNIT_SEPARATOR
+	if signer != nil {
+		copy(hm.outBytes[headerSize+HEADER_FRAMING_SIZE:], hm.msgBytes)
+		return hm.outBytes, nil
+	}
 	hm.buf.SetBuf(hm.outBytes[headerSize+HEADER_FRAMING_SIZE : headerSize+HEADER_FRAMING_SIZE])
 	if err := hm.buf.Marshal(hm.msg); err != nil {
 		return nil, err
@@ -90,71 +146,313 @@ func NewProtobufEmitter(writer io.Writer, envVersion,
 	hostname, loggerName string) *ProtobufEmitter {
 
 	return &ProtobufEmitter{
-		Writer:       writer,
-		LogName:      loggerName,
-		Pid:          int32(os.Getpid()),
-		EnvVersion:   envVersion,
-		Hostname:     hostname,
-		UseMockFuncs: false,
+		Writer:     writer,
+		LogName:    loggerName,
+		EnvVersion: envVersion,
+		Hostname:   hostname,
 	}
 }
 
 // A ProtobufEmitter emits framed, Protobuf-encoded log messages.
+// If HmacKey is set, messages are signed. If BatchSize is set, frames
+// are gathered and written together when batch reaches BatchSize bytes
+// or FlushInterval passes after first frame in batch. Error of a flush
+// made by timer is returned from next Emit, whose message is still sent.
+// Time, pid and IDs of messages made by Emit are taken from Environment
+// (golog.SystemEnvironment if it isn't set), Pid overrides pid of environment.
 type ProtobufEmitter struct {
 	io.Writer
-	LogName      string
-	Pid          int32
-	EnvVersion   string
-	Hostname     string
-	UseMockFuncs bool
+	LogName          string
+	Pid              int32
+	EnvVersion       string
+	Hostname         string
+	Environment      golog.Environment
+	HmacSigner       string
+	HmacKeyVersion   uint32
+	HmacKey          string
+	HmacHashFunction Header_HmacHashFunction
+	BatchSize        int
+	FlushInterval    time.Duration
+	Oversized        OversizedPolicy
+
+	mu       sync.Mutex
+	batch    []byte
+	timer    *time.Timer
+	flushErr error
+	dropped  int64
 }
 
-// Emit encodes and sends a framed log message.
-func (pe *ProtobufEmitter) Emit(level int32, messageType, payload string,
-	fields map[string]string) (err error) {
+// Configure sets emitter options from configuration keys:
+//
+//	batch_size     - frames are written when batch reaches this number of bytes
+//	flush_interval - maximum delay of batched frames (e.g. "100ms"), default is 1s
+//	oversized      - what to do with too big messages: "truncate" (default),
+//	                 "split" or "drop"
+//
+// Signing options are described in ConfigureHmac.
+func (pe *ProtobufEmitter) Configure(cnf map[string]string) error {
+	if val := cnf["batch_size"]; val != "" {
+		size, err := strconv.Atoi(val)
+		if err != nil {
+			return fmt.Errorf("Invalid batch_size %q: %s", val, err)
+		}
+		pe.BatchSize = size
+	}
 
-	msgID, err := id.GenerateBytes()
-	if err != nil {
-		return fmt.Errorf("Error generating Protobuf log message ID: %s", err)
+	if val := cnf["flush_interval"]; val != "" {
+		interval, err := time.ParseDuration(val)
+		if err != nil {
+			return fmt.Errorf("Invalid flush_interval %q: %s", val, err)
+		}
+		pe.FlushInterval = interval
+	}
+
+	switch cnf["oversized"] {
+	case "", "truncate":
+		pe.Oversized = OversizedTruncate
+	case "split":
+		pe.Oversized = OversizedSplit
+	case "drop":
+		pe.Oversized = OversizedDrop
+	default:
+		return fmt.Errorf("Unsupported oversized policy %q", cnf["oversized"])
 	}
 
+	return pe.ConfigureHmac(cnf)
+}
+
+// ConfigureHmac sets message signing options from configuration keys
+// "hmac_signer", "hmac_key_version", "hmac_key" and "hmac_hash"
+// ("md5" or "sha1", md5 is default). Messages are signed only if
+// "hmac_key" is not empty.
+func (pe *ProtobufEmitter) ConfigureHmac(cnf map[string]string) error {
+	if cnf["hmac_key"] == "" {
+		return nil
+	}
+
+	if cnf["hmac_signer"] == "" {
+		return fmt.Errorf("Missing hmac_signer for signing Heka messages")
+	}
+
+	var version uint64
+	if val := cnf["hmac_key_version"]; val != "" {
+		var err error
+		if version, err = strconv.ParseUint(val, 10, 32); err != nil {
+			return fmt.Errorf("Invalid hmac_key_version %q: %s", val, err)
+		}
+	}
+
+	switch strings.ToLower(cnf["hmac_hash"]) {
+	case "", "md5":
+		pe.HmacHashFunction = Header_MD5
+	case "sha1":
+		pe.HmacHashFunction = Header_SHA1
+	default:
+		return fmt.Errorf("Unsupported hmac_hash %q", cnf["hmac_hash"])
+	}
+
+	pe.HmacSigner = cnf["hmac_signer"]
+	pe.HmacKeyVersion = uint32(version)
+	pe.HmacKey = cnf["hmac_key"]
+	return nil
+}
+
+func (pe *ProtobufEmitter) signer() *hmacSigner {
+	if pe.HmacKey == "" {
+		return nil
+	}
+
+	return &hmacSigner{
+		name:     pe.HmacSigner,
+		version:  pe.HmacKeyVersion,
+		key:      []byte(pe.HmacKey),
+		hashFunc: pe.HmacHashFunction,
+	}
+}
+
+// Emit encodes and sends a framed log message.
+// Fields can be created with NewField.
+func (pe *ProtobufEmitter) Emit(level int32, messageType, payload string,
+	fields []*Field) (err error) {
+
 	hm := newHekaMessage()
 	defer hm.free()
 
-	if pe.UseMockFuncs {
-		hm.msg.SetID(idGenerateBytes())
-		hm.msg.SetTimestamp(timeNow().UnixNano())
-		hm.msg.SetPid(varosGetPid())
-	} else {
-		hm.msg.SetID(msgID)
-		hm.msg.SetTimestamp(time.Now().UnixNano())
-		hm.msg.SetPid(pe.Pid)
+	if err = pe.setID(hm); err != nil {
+		return err
 	}
 
+	env := pe.env()
+	pid := pe.Pid
+	if pid == 0 {
+		pid = int32(env.Pid())
+	}
+
+	hm.msg.SetTimestamp(env.Now().UnixNano())
+	hm.msg.SetPid(pid)
+
 	hm.msg.SetType(messageType)
 	hm.msg.SetLogger(pe.LogName)
 	hm.msg.SetSeverity(level)
 	hm.msg.SetPayload(payload)
 	hm.msg.SetEnvVersion(pe.EnvVersion)
 	hm.msg.SetHostname(pe.Hostname)
-	if fields != nil {
-		for name, val := range fields {
-			hm.msg.AddStringField(name, val)
-		}
+	hm.msg.Fields = append(hm.msg.Fields, fields...)
+	return pe.send(hm)
+}
+
+// EmitLog encodes and sends log entry. Logger name, time, pid
+// and severity are taken from the entry, ID of entry is used
+// as message Uuid, data is converted to message fields with DataFields.
+func (pe *ProtobufEmitter) EmitLog(messageType string, log golog.Log) error {
+	hm := newHekaMessage()
+	defer hm.free()
+
+	if len(log.ID) == 16 {
+		hm.msg.SetID(log.ID)
+	} else if err := pe.setID(hm); err != nil {
+		return err
+	}
+
+	logName := pe.LogName
+	if log.Logger != nil {
+		logName = log.Logger.Name
+	}
+
+	hm.msg.SetTimestamp(log.Time.UnixNano())
+	hm.msg.SetPid(int32(log.Pid))
+	hm.msg.SetType(messageType)
+	hm.msg.SetLogger(logName)
+	hm.msg.SetSeverity(int32(log.Level))
+	hm.msg.SetPayload(log.Message)
+	hm.msg.SetEnvVersion(pe.EnvVersion)
+	hm.msg.SetHostname(pe.Hostname)
+	hm.msg.Fields = DataFields(log.Data)
+	return pe.send(hm)
+}
+
+func (pe *ProtobufEmitter) env() golog.Environment {
+	if pe.Environment == nil {
+		return golog.SystemEnvironment
+	}
+	return pe.Environment
+}
+
+func (pe *ProtobufEmitter) setID(hm *hekaMessage) error {
+	msgID, err := pe.env().NewID()
+	if err != nil {
+		return fmt.Errorf("Error generating Protobuf log message ID: %s", err)
 	}
+	hm.msg.SetID(msgID)
+	return nil
+}
+
+func (pe *ProtobufEmitter) send(hm *hekaMessage) error {
 	hm.msg.SortFields()
 
-	outBytes, err := hm.marshalFrame()
+	if hm.msg.Size() > MAX_MESSAGE_SIZE {
+		switch pe.Oversized {
+		case OversizedSplit:
+			return pe.sendSplit(hm)
+		case OversizedDrop:
+			atomic.AddInt64(&pe.dropped, 1)
+			return nil
+		default:
+			if !truncate(hm.msg) {
+				atomic.AddInt64(&pe.dropped, 1)
+				return fmt.Errorf("Message fields exceed maximum size %d", MAX_MESSAGE_SIZE)
+			}
+		}
+	}
+
+	return pe.write(hm)
+}
+
+func (pe *ProtobufEmitter) write(hm *hekaMessage) error {
+	outBytes, err := hm.marshalFrame(pe.signer())
 	if err != nil {
 		return fmt.Errorf("Error encoding Protobuf log message: %s", err)
 	}
-	if _, err = pe.Writer.Write(outBytes); err != nil {
-		return fmt.Errorf("Error sending Protobuf log message: %s", err)
+
+	pe.mu.Lock()
+	defer pe.mu.Unlock()
+
+	// message is sent anyway, failed flush concerns earlier ones
+	flushErr := pe.flushErr
+	pe.flushErr = nil
+
+	if pe.BatchSize <= 0 {
+		if _, err = pe.Writer.Write(outBytes); err != nil {
+			err = fmt.Errorf("Error sending Protobuf log message: %s", err)
+		}
+		return errors.Join(flushErr, err)
+	}
+
+	pe.batch = append(pe.batch, outBytes...)
+	if len(pe.batch) >= pe.BatchSize {
+		return errors.Join(flushErr, pe.flush())
+	}
+
+	if pe.timer == nil {
+		interval := pe.FlushInterval
+		if interval <= 0 {
+			interval = time.Second
+		}
+		pe.timer = time.AfterFunc(interval, pe.flushTimer)
+	}
+	return flushErr
+}
+
+// Flush writes batched frames.
+func (pe *ProtobufEmitter) Flush() error {
+	pe.mu.Lock()
+	defer pe.mu.Unlock()
+
+	return pe.flush()
+}
+
+// errors of flushes made by timer are returned from next Emit
+// together with its own error
+func (pe *ProtobufEmitter) flushTimer() {
+	pe.mu.Lock()
+	defer pe.mu.Unlock()
+
+	pe.timer = nil
+	if err := pe.flush(); err != nil {
+		pe.flushErr = err
+	}
+}
+
+func (pe *ProtobufEmitter) flush() error {
+	if pe.timer != nil {
+		pe.timer.Stop()
+		pe.timer = nil
+	}
+
+	if len(pe.batch) == 0 {
+		return nil
+	}
+
+	_, err := pe.Writer.Write(pe.batch)
+	pe.batch = pe.batch[:0]
+	if err != nil {
+		return fmt.Errorf("Error sending Protobuf log messages: %s", err)
 	}
 	return nil
 }
 
-// Close closes the underlying write stream. Implements LogEmitter.Close.
+// Dropped returns number of messages which were dropped
+// because of their size.
+func (pe *ProtobufEmitter) Dropped() int64 {
+	return atomic.LoadInt64(&pe.dropped)
+}
+
+// Close flushes batched frames and closes the underlying write stream.
+// Implements LogEmitter.Close.
 func (pe *ProtobufEmitter) Close() error {
-	return TryClose(pe.Writer)
+	err := pe.Flush()
+	if closeErr := TryClose(pe.Writer); err == nil {
+		err = closeErr
+	}
+	return err
 }
@@ -0,0 +1,225 @@
+package heka_emitter
+
+import (
+	"bytes"
+	"errors"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// records every write separately
+type writesRecorder struct {
+	mu     sync.Mutex
+	writes [][]byte
+}
+
+func (wr *writesRecorder) Write(p []byte) (int, error) {
+	wr.mu.Lock()
+	defer wr.mu.Unlock()
+
+	wr.writes = append(wr.writes, append([]byte(nil), p...))
+	return len(p), nil
+}
+
+func (wr *writesRecorder) count() int {
+	wr.mu.Lock()
+	defer wr.mu.Unlock()
+
+	return len(wr.writes)
+}
+
+func (wr *writesRecorder) payloads(t *testing.T) []string {
+	wr.mu.Lock()
+	defer wr.mu.Unlock()
+
+	decoder := NewDecoder(bytes.NewReader(bytes.Join(wr.writes, nil)))
+	var payloads []string
+	for {
+		msg, err := decoder.Decode()
+		if err != nil {
+			return payloads
+		}
+		payloads = append(payloads, msg.GetPayload())
+	}
+}
+
+func TestEmitterConfigure(t *testing.T) {
+	pe := NewProtobufEmitter(nil, "", "", "")
+	err := pe.Configure(map[string]string{
+		"batch_size":     "4096",
+		"flush_interval": "50ms",
+		"oversized":      "split",
+	})
+	assert.Nil(t, err)
+	assert.Equal(t, 4096, pe.BatchSize)
+	assert.Equal(t, 50*time.Millisecond, pe.FlushInterval)
+	assert.Equal(t, OversizedSplit, pe.Oversized)
+
+	assert.NotNil(t, pe.Configure(map[string]string{"batch_size": "big"}))
+	assert.NotNil(t, pe.Configure(map[string]string{"flush_interval": "soon"}))
+	assert.NotNil(t, pe.Configure(map[string]string{"oversized": "ignore"}))
+	assert.NotNil(t, pe.Configure(map[string]string{"hmac_key": "secret"}))
+}
+
+func TestEmitterBatchSize(t *testing.T) {
+	wr := &writesRecorder{}
+	pe := NewProtobufEmitter(wr, "", "", "test")
+	pe.BatchSize = 250
+	pe.FlushInterval = time.Hour
+
+	// every frame is about 100 bytes
+	emitFrames(t, pe, "first", "second", "third", "fourth")
+	assert.Equal(t, 1, wr.count())
+	assert.Equal(t, []string{"first", "second", "third"}, wr.payloads(t))
+
+	assert.Nil(t, pe.Flush())
+	assert.Equal(t, 2, wr.count())
+	assert.Equal(t, []string{"first", "second", "third", "fourth"}, wr.payloads(t))
+
+	// nothing to flush
+	assert.Nil(t, pe.Close())
+	assert.Equal(t, 2, wr.count())
+}
+
+func TestEmitterFlushInterval(t *testing.T) {
+	wr := &writesRecorder{}
+	pe := NewProtobufEmitter(wr, "", "", "test")
+	pe.BatchSize = 1 << 20
+	pe.FlushInterval = 10 * time.Millisecond
+
+	emitFrames(t, pe, "first", "second")
+	assert.Equal(t, 0, wr.count())
+
+	deadline := time.Now().Add(5 * time.Second)
+	for wr.count() == 0 && time.Now().Before(deadline) {
+		time.Sleep(time.Millisecond)
+	}
+
+	assert.Equal(t, 1, wr.count())
+	assert.Equal(t, []string{"first", "second"}, wr.payloads(t))
+}
+
+// fails on first write
+type failOnceWriter struct {
+	writesRecorder
+	failed bool
+}
+
+func (fw *failOnceWriter) Write(p []byte) (int, error) {
+	fw.mu.Lock()
+	if !fw.failed {
+		fw.failed = true
+		fw.mu.Unlock()
+		return 0, errors.New("connection refused")
+	}
+	fw.mu.Unlock()
+	return fw.writesRecorder.Write(p)
+}
+
+func TestEmitterFlushError(t *testing.T) {
+	fw := &failOnceWriter{}
+	pe := NewProtobufEmitter(fw, "", "", "test")
+	pe.BatchSize = 1 << 20
+	pe.FlushInterval = time.Millisecond
+
+	emitFrames(t, pe, "lost")
+
+	deadline := time.Now().Add(5 * time.Second)
+	for {
+		pe.mu.Lock()
+		failed := pe.flushErr != nil
+		pe.mu.Unlock()
+		if failed || time.Now().After(deadline) {
+			break
+		}
+		time.Sleep(time.Millisecond)
+	}
+
+	// error of timer flush is reported, but message is kept
+	field, _ := NewField("number", 1, "count")
+	err := pe.Emit(6, "test", "after", []*Field{field})
+	assert.Contains(t, err.Error(), "connection refused")
+
+	assert.Nil(t, pe.Flush())
+	assert.Equal(t, []string{"after"}, fw.payloads(t))
+}
+
+func TestEmitterOversizedTruncate(t *testing.T) {
+	buf := new(bytes.Buffer)
+	pe := newTestEmitter(buf)
+
+	payload := strings.Repeat("ж", MAX_MESSAGE_SIZE)
+	emitFrames(t, pe, payload)
+
+	msg, err := NewDecoder(buf).Decode()
+	assert.Nil(t, err)
+	assert.True(t, msg.Size() <= MAX_MESSAGE_SIZE)
+	assert.True(t, strings.HasPrefix(payload, msg.GetPayload()))
+	assert.True(t, len(msg.GetPayload()) > MAX_MESSAGE_SIZE-200)
+
+	var truncated *Field
+	for _, field := range msg.Fields {
+		if field.GetName() == TruncatedField {
+			truncated = field
+		}
+	}
+	assert.NotNil(t, truncated)
+	assert.Equal(t, []int64{int64(len(payload))}, truncated.GetValueInteger())
+}
+
+func TestEmitterOversizedSplit(t *testing.T) {
+	buf := new(bytes.Buffer)
+	pe := NewProtobufEmitter(buf, "", "", "test")
+	pe.Oversized = OversizedSplit
+
+	payload := strings.Repeat("ж", MAX_MESSAGE_SIZE)
+	emitFrames(t, pe, payload)
+
+	decoder := NewDecoder(buf)
+	var (
+		joined  string
+		splitId string
+		ids     = map[string]bool{}
+	)
+
+	for part := 1; part <= 3; part++ {
+		msg, err := decoder.Decode()
+		assert.Nil(t, err)
+		assert.True(t, msg.Size() <= MAX_MESSAGE_SIZE)
+		ids[string(msg.Uuid)] = true
+		joined += msg.GetPayload()
+
+		fields := map[string]*Field{}
+		for _, field := range msg.Fields {
+			fields[field.GetName()] = field
+		}
+
+		assert.Equal(t, []int64{int64(part)}, fields[SplitPartField].GetValueInteger())
+		assert.Equal(t, []int64{3}, fields[SplitPartsField].GetValueInteger())
+		assert.Equal(t, []int64{int64(len(payload))}, fields["number"].GetValueInteger())
+		if part == 1 {
+			splitId = fields[SplitIdField].GetValueString()[0]
+		}
+		assert.Equal(t, splitId, fields[SplitIdField].GetValueString()[0])
+	}
+
+	assert.Equal(t, payload, joined)
+	assert.Len(t, ids, 3)
+}
+
+func TestEmitterOversizedDrop(t *testing.T) {
+	buf := new(bytes.Buffer)
+	pe := newTestEmitter(buf)
+	pe.Oversized = OversizedDrop
+
+	emitFrames(t, pe, strings.Repeat("a", MAX_MESSAGE_SIZE), "small")
+	assert.Equal(t, int64(1), pe.Dropped())
+
+	msg, err := NewDecoder(buf).Decode()
+	assert.Nil(t, err)
+	assert.Equal(t, "small", msg.GetPayload())
+}
@@ -0,0 +1,173 @@
+/* This Source Code Form is subject to the terms of the Mozilla Public
+ * License, v. 2.0. If a copy of the MPL was not distributed with this
+ * file, You can obtain one at http://mozilla.org/MPL/2.0/. */
+
+package heka_emitter
+
+import (
+	"fmt"
+	"math"
+	"reflect"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// values nested deeper are not converted, this also protects from cycles
+const fieldsDepth = 8
+
+var errorType = reflect.TypeOf((*error)(nil)).Elem()
+
+// DataFields converts log data to typed fields. Maps and structs are
+// flattened into dotted names ("user.address.city"), errors are stored in
+// "error" field, and other values are named by their position ("data.1").
+func DataFields(data []interface{}) []*Field {
+	var fields []*Field
+
+	for i, item := range data {
+		if err, ok := item.(error); ok {
+			fields = appendField(fields, "error", err.Error())
+			continue
+		}
+
+		value := reflect.Indirect(reflect.ValueOf(item))
+		if hasChildren(value) {
+			fields = flattenFields(fields, "", value, 0)
+		} else {
+			fields = flattenFields(fields, "data."+strconv.Itoa(i), value, 0)
+		}
+	}
+
+	return fields
+}
+
+// is value expanded into named fields, times and
+// errors are single values even if they are structs
+func hasChildren(value reflect.Value) bool {
+	switch value.Kind() {
+	case reflect.Map, reflect.Struct:
+	default:
+		return false
+	}
+
+	if value.Type().Implements(errorType) {
+		return false
+	}
+	_, isTime := value.Interface().(time.Time)
+	return !isTime
+}
+
+func flattenFields(fields []*Field, name string,
+	value reflect.Value, depth int) []*Field {
+
+	if !value.IsValid() || depth > fieldsDepth {
+		return fields
+	}
+
+	switch value.Kind() {
+	case reflect.Ptr, reflect.Interface, reflect.Map, reflect.Slice:
+		if value.IsNil() {
+			return fields
+		}
+	}
+
+	if value.Type().Implements(errorType) {
+		return appendField(fields, name, value.Interface().(error).Error())
+	}
+
+	switch v := value.Interface().(type) {
+	case time.Time:
+		return appendField(fields, name, v.Format(time.RFC3339Nano))
+	case []byte:
+		return appendField(fields, name, v)
+	}
+
+	switch value.Kind() {
+	case reflect.Ptr, reflect.Interface:
+		return flattenFields(fields, name, value.Elem(), depth+1)
+	case reflect.Map:
+		keys := value.MapKeys()
+		names := make([]string, len(keys))
+		for i, key := range keys {
+			names[i] = fmt.Sprint(key.Interface())
+		}
+		sort.Sort(byName{names, keys})
+
+		for i, key := range keys {
+			fields = flattenFields(fields, joinFieldName(name, names[i]),
+				value.MapIndex(key), depth+1)
+		}
+	case reflect.Struct:
+		t := value.Type()
+		for i := 0; i < t.NumField(); i++ {
+			field := t.Field(i)
+			if field.PkgPath != "" {
+				continue
+			}
+
+			fieldName := field.Name
+			if tag := strings.Split(field.Tag.Get("json"), ",")[0]; tag == "-" {
+				continue
+			} else if tag != "" {
+				fieldName = tag
+			}
+
+			fields = flattenFields(fields, joinFieldName(name, fieldName),
+				value.Field(i), depth+1)
+		}
+	case reflect.Slice, reflect.Array:
+		for i := 0; i < value.Len(); i++ {
+			fields = flattenFields(fields, joinFieldName(name, strconv.Itoa(i)),
+				value.Index(i), depth+1)
+		}
+	case reflect.String:
+		fields = appendField(fields, name, value.String())
+	case reflect.Bool:
+		fields = appendField(fields, name, value.Bool())
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		fields = appendField(fields, name, value.Int())
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64, reflect.Uintptr:
+		if value.Uint() > math.MaxInt64 {
+			fields = appendField(fields, name, strconv.FormatUint(value.Uint(), 10))
+		} else {
+			fields = appendField(fields, name, int64(value.Uint()))
+		}
+	case reflect.Float32, reflect.Float64:
+		fields = appendField(fields, name, value.Float())
+	default:
+		fields = appendField(fields, name, fmt.Sprint(value.Interface()))
+	}
+
+	return fields
+}
+
+func appendField(fields []*Field, name string,
+	value interface{}) []*Field {
+
+	field, err := NewField(name, value, "")
+	if err != nil {
+		return fields
+	}
+	return append(fields, field)
+}
+
+func joinFieldName(prefix, name string) string {
+	if prefix == "" {
+		return name
+	}
+	return prefix + "." + name
+}
+
+// sorts map keys by their string representation
+type byName struct {
+	names []string
+	keys  []reflect.Value
+}
+
+func (b byName) Len() int           { return len(b.names) }
+func (b byName) Less(i, j int) bool { return b.names[i] < b.names[j] }
+func (b byName) Swap(i, j int) {
+	b.names[i], b.names[j] = b.names[j], b.names[i]
+	b.keys[i], b.keys[j] = b.keys[j], b.keys[i]
+}
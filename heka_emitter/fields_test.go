@@ -0,0 +1,90 @@
+package heka_emitter
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+type testAddress struct {
+	City   string
+	Zip    int `json:"zip"`
+	secret string
+	Hidden string `json:"-"`
+}
+
+type testUser struct {
+	Name    string
+	Address *testAddress
+	Tags    []string
+	Err     error
+}
+
+func TestDataFields(t *testing.T) {
+	fields := DataFields([]interface{}{
+		map[string]string{"b": "x"},
+		testUser{
+			Name:    "bob",
+			Address: &testAddress{City: "Paris", Zip: 75001, secret: "s", Hidden: "h"},
+			Tags:    []string{"admin"},
+			Err:     errors.New("inner"),
+		},
+		map[string]interface{}{"nested": map[string]interface{}{"ok": true, "ratio": 1.5}},
+		errors.New("failure"),
+		uint64(7),
+		nil,
+	})
+
+	values := map[string]interface{}{}
+	for _, field := range fields {
+		switch field.GetValueType() {
+		case Field_STRING:
+			values[field.GetName()] = field.GetValueString()[0]
+		case Field_INTEGER:
+			values[field.GetName()] = field.GetValueInteger()[0]
+		case Field_DOUBLE:
+			values[field.GetName()] = field.GetValueDouble()[0]
+		case Field_BOOL:
+			values[field.GetName()] = field.GetValueBool()[0]
+		}
+	}
+
+	assert.Equal(t, map[string]interface{}{
+		"b":            "x",
+		"Name":         "bob",
+		"Address.City": "Paris",
+		"Address.zip":  int64(75001),
+		"Tags.0":       "admin",
+		"Err":          "inner",
+		"nested.ok":    true,
+		"nested.ratio": 1.5,
+		"error":        "failure",
+		"data.4":       int64(7),
+	}, values)
+}
+
+type testCycle struct {
+	Name string
+	Next *testCycle
+}
+
+func TestDataFieldsCycle(t *testing.T) {
+	cycle := &testCycle{Name: "loop"}
+	cycle.Next = cycle
+
+	fields := DataFields([]interface{}{cycle})
+	assert.NotEmpty(t, fields)
+	assert.Equal(t, "Name", fields[0].GetName())
+}
+
+func TestDataFieldsTime(t *testing.T) {
+	at := time.Date(2009, 11, 10, 23, 0, 0, 0, time.UTC)
+	fields := DataFields([]interface{}{"first", at, &at})
+
+	assert.Len(t, fields, 3)
+	assert.Equal(t, "data.1", fields[1].GetName())
+	assert.Equal(t, []string{"2009-11-10T23:00:00Z"}, fields[1].GetValueString())
+	assert.Equal(t, "data.2", fields[2].GetName())
+}
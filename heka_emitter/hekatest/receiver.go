@@ -0,0 +1,237 @@
+// Package hekatest provides in-process Heka receiver
+// for testing code which sends messages to Heka.
+package hekatest
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"net"
+	"sync"
+	"time"
+
+	"github.com/ildus/golog/heka_emitter"
+)
+
+// Receiver listens on local TCP or UDP port and collects decoded
+// messages. Frames which can't be decoded are collected as errors.
+type Receiver struct {
+	// network and address receiver listens on
+	Network string
+	Addr    string
+
+	listener net.Listener
+	packets  net.PacketConn
+
+	mu       sync.Mutex
+	cond     *sync.Cond
+	keys     map[string]hmacKey
+	messages []*heka_emitter.Message
+	errors   []error
+	conns    map[net.Conn]bool
+	closed   bool
+	wg       sync.WaitGroup
+}
+
+type hmacKey struct {
+	signer  string
+	version uint32
+	key     string
+}
+
+// NewReceiver starts receiver on random port of 127.0.0.1.
+// Network should be "tcp" or "udp".
+func NewReceiver(network string) (*Receiver, error) {
+	r := &Receiver{
+		Network: network,
+		keys:    map[string]hmacKey{},
+		conns:   map[net.Conn]bool{},
+	}
+	r.cond = sync.NewCond(&r.mu)
+
+	switch network {
+	case "tcp":
+		ln, err := net.Listen(network, "127.0.0.1:0")
+		if err != nil {
+			return nil, err
+		}
+		r.listener = ln
+		r.Addr = ln.Addr().String()
+		r.wg.Add(1)
+		go r.accept()
+	case "udp":
+		pc, err := net.ListenPacket(network, "127.0.0.1:0")
+		if err != nil {
+			return nil, err
+		}
+		r.packets = pc
+		r.Addr = pc.LocalAddr().String()
+		r.wg.Add(1)
+		go r.readPackets()
+	default:
+		return nil, fmt.Errorf("Unsupported network %q", network)
+	}
+
+	return r, nil
+}
+
+// AddHmacKey adds key for verifying signed messages,
+// look at heka_emitter.Decoder.AddHmacKey.
+func (r *Receiver) AddHmacKey(signer string, keyVersion uint32, key string) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	r.keys[fmt.Sprintf("%s_%d", signer, keyVersion)] = hmacKey{signer, keyVersion, key}
+}
+
+// Messages returns all messages received so far.
+func (r *Receiver) Messages() []*heka_emitter.Message {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	return append([]*heka_emitter.Message(nil), r.messages...)
+}
+
+// Errors returns decoding errors of frames received so far.
+func (r *Receiver) Errors() []error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	return append([]error(nil), r.errors...)
+}
+
+// Wait waits until at least count messages are received and returns
+// all received messages. Error is returned if timeout expires first.
+func (r *Receiver) Wait(count int, timeout time.Duration) ([]*heka_emitter.Message, error) {
+	timer := time.AfterFunc(timeout, func() {
+		r.mu.Lock()
+		r.cond.Broadcast()
+		r.mu.Unlock()
+	})
+	defer timer.Stop()
+
+	deadline := time.Now().Add(timeout)
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	for len(r.messages) < count {
+		if r.closed || !time.Now().Before(deadline) {
+			return append([]*heka_emitter.Message(nil), r.messages...),
+				fmt.Errorf("Received %d messages, expected %d", len(r.messages), count)
+		}
+		r.cond.Wait()
+	}
+
+	return append([]*heka_emitter.Message(nil), r.messages...), nil
+}
+
+// Reset forgets received messages and errors.
+func (r *Receiver) Reset() {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	r.messages = nil
+	r.errors = nil
+}
+
+// Close stops receiver and closes all accepted connections.
+func (r *Receiver) Close() error {
+	r.mu.Lock()
+	r.closed = true
+	for conn := range r.conns {
+		conn.Close()
+	}
+	r.cond.Broadcast()
+	r.mu.Unlock()
+
+	var err error
+	if r.listener != nil {
+		err = r.listener.Close()
+	} else {
+		err = r.packets.Close()
+	}
+
+	r.wg.Wait()
+	return err
+}
+
+func (r *Receiver) accept() {
+	defer r.wg.Done()
+
+	for {
+		conn, err := r.listener.Accept()
+		if err != nil {
+			return
+		}
+
+		r.mu.Lock()
+		if r.closed {
+			r.mu.Unlock()
+			conn.Close()
+			return
+		}
+		r.conns[conn] = true
+		r.mu.Unlock()
+
+		r.wg.Add(1)
+		go r.read(conn)
+	}
+}
+
+func (r *Receiver) read(conn net.Conn) {
+	defer r.wg.Done()
+	defer func() {
+		r.mu.Lock()
+		delete(r.conns, conn)
+		r.mu.Unlock()
+		conn.Close()
+	}()
+
+	r.decode(conn)
+}
+
+// every datagram carries one frame
+func (r *Receiver) readPackets() {
+	defer r.wg.Done()
+
+	buf := make([]byte, 1<<17)
+	for {
+		n, _, err := r.packets.ReadFrom(buf)
+		if err != nil {
+			return
+		}
+		r.decode(bytes.NewReader(buf[:n]))
+	}
+}
+
+func (r *Receiver) decode(reader io.Reader) {
+	decoder := heka_emitter.NewDecoder(reader)
+
+	r.mu.Lock()
+	for _, key := range r.keys {
+		decoder.AddHmacKey(key.signer, key.version, key.key)
+	}
+	r.mu.Unlock()
+
+	for {
+		msg, err := decoder.Decode()
+		if err == io.EOF {
+			return
+		}
+
+		r.mu.Lock()
+		if err != nil {
+			r.errors = append(r.errors, err)
+		} else {
+			r.messages = append(r.messages, msg)
+		}
+		r.cond.Broadcast()
+		r.mu.Unlock()
+
+		// connection is broken, stop reading
+		if decoder.Err() != nil {
+			return
+		}
+	}
+}
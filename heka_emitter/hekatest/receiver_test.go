@@ -0,0 +1,76 @@
+package hekatest
+
+import (
+	"net"
+	"testing"
+	"time"
+
+	"github.com/ildus/golog/heka_emitter"
+	"github.com/stretchr/testify/assert"
+)
+
+func emit(t *testing.T, network, addr string, signed bool, payloads ...string) {
+	conn, err := net.Dial(network, addr)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer conn.Close()
+
+	pe := heka_emitter.NewProtobufEmitter(conn, "1", "localhost", "hekatest")
+	if signed {
+		pe.ConfigureHmac(map[string]string{
+			"hmac_signer": "golog",
+			"hmac_key":    "secret",
+		})
+	}
+
+	for _, payload := range payloads {
+		if err := pe.Emit(6, "test", payload, nil); err != nil {
+			t.Fatal(err)
+		}
+	}
+}
+
+func TestReceiver(t *testing.T) {
+	for _, network := range []string{"tcp", "udp"} {
+		r, err := NewReceiver(network)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		emit(t, network, r.Addr, false, "first", "second")
+		messages, err := r.Wait(2, 5*time.Second)
+		assert.Nil(t, err)
+		assert.Len(t, messages, 2)
+		assert.Equal(t, "first", messages[0].GetPayload())
+		assert.Equal(t, "second", messages[1].GetPayload())
+		assert.Equal(t, "hekatest", messages[0].GetLogger())
+
+		r.Reset()
+		assert.Empty(t, r.Messages())
+
+		_, err = r.Wait(1, 10*time.Millisecond)
+		assert.NotNil(t, err)
+
+		assert.Nil(t, r.Close())
+	}
+}
+
+func TestReceiverHmac(t *testing.T) {
+	r, err := NewReceiver("tcp")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer r.Close()
+
+	emit(t, "tcp", r.Addr, true, "unknown signer")
+	time.Sleep(10 * time.Millisecond)
+
+	r.AddHmacKey("golog", 0, "secret")
+	emit(t, "tcp", r.Addr, true, "signed")
+
+	messages, err := r.Wait(1, 5*time.Second)
+	assert.Nil(t, err)
+	assert.Equal(t, "signed", messages[0].GetPayload())
+	assert.Equal(t, []error{heka_emitter.ErrUnknownSigner}, r.Errors())
+}
@@ -5,6 +5,7 @@
 package heka_emitter
 
 import (
+	"fmt"
 	"sort"
 )
 
@@ -35,6 +36,15 @@ func (h *Header) SetMessageLength(v uint32) {
 	*h.MessageLength = v
 }
 
+func (h *Header) SetHmac(hashFunc Header_HmacHashFunction, signer string,
+	keyVersion uint32, hmac []byte) {
+
+	h.HmacHashFunction = hashFunc.Enum()
+	h.HmacSigner = &signer
+	h.HmacKeyVersion = &keyVersion
+	h.Hmac = hmac
+}
+
 func (m *Message) SetID(msgID []byte) {
 	m.Uuid = msgID
 }
@@ -100,12 +110,74 @@ func (m *Message) SortFields() {
 }
 
 func (m *Message) AddStringField(name, val string) {
+	f, _ := NewField(name, val, "")
+	m.Fields = append(m.Fields, f)
+}
+
+// NewField creates field with value type matching the type of value.
+// Strings, byte slices, integers, floats and bools are supported.
+// Representation describes value for consumers (e.g. "ms" or "count").
+func NewField(name string, value interface{}, representation string) (*Field, error) {
 	f := &Field{
 		Name:           &name,
 		ValueType:      new(Field_ValueType),
-		Representation: new(string),
-		ValueString:    []string{val},
+		Representation: &representation,
 	}
-	*f.ValueType = Field_STRING
+
+	switch v := value.(type) {
+	case string:
+		*f.ValueType = Field_STRING
+		f.ValueString = []string{v}
+	case []byte:
+		*f.ValueType = Field_BYTES
+		f.ValueBytes = [][]byte{v}
+	case int:
+		*f.ValueType = Field_INTEGER
+		f.ValueInteger = []int64{int64(v)}
+	case int8:
+		*f.ValueType = Field_INTEGER
+		f.ValueInteger = []int64{int64(v)}
+	case int16:
+		*f.ValueType = Field_INTEGER
+		f.ValueInteger = []int64{int64(v)}
+	case int32:
+		*f.ValueType = Field_INTEGER
+		f.ValueInteger = []int64{int64(v)}
+	case int64:
+		*f.ValueType = Field_INTEGER
+		f.ValueInteger = []int64{v}
+	case uint8:
+		*f.ValueType = Field_INTEGER
+		f.ValueInteger = []int64{int64(v)}
+	case uint16:
+		*f.ValueType = Field_INTEGER
+		f.ValueInteger = []int64{int64(v)}
+	case uint32:
+		*f.ValueType = Field_INTEGER
+		f.ValueInteger = []int64{int64(v)}
+	case float32:
+		*f.ValueType = Field_DOUBLE
+		f.ValueDouble = []float64{float64(v)}
+	case float64:
+		*f.ValueType = Field_DOUBLE
+		f.ValueDouble = []float64{v}
+	case bool:
+		*f.ValueType = Field_BOOL
+		f.ValueBool = []bool{v}
+	default:
+		return nil, fmt.Errorf("Unsupported value type %T of field %q", value, name)
+	}
+
+	return f, nil
+}
+
+// AddField adds typed field to message, look at NewField for supported types.
+func (m *Message) AddField(name string, value interface{}, representation string) error {
+	f, err := NewField(name, value, representation)
+	if err != nil {
+		return err
+	}
+
 	m.Fields = append(m.Fields, f)
+	return nil
 }
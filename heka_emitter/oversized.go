@@ -0,0 +1,124 @@
+/* This Source Code Form is subject to the terms of the Mozilla Public
+ * License, v. 2.0. If a copy of the MPL was not distributed with this
+ * file, You can obtain one at http://mozilla.org/MPL/2.0/. */
+
+package heka_emitter
+
+import (
+	"fmt"
+	"sync/atomic"
+	"unicode/utf8"
+
+	"github.com/ildus/golog/id"
+)
+
+// OversizedPolicy defines what emitter does with messages
+// bigger than MAX_MESSAGE_SIZE.
+type OversizedPolicy int
+
+const (
+	// payload is cut, message gets TruncatedField with original payload length
+	OversizedTruncate OversizedPolicy = iota
+
+	// payload is sent in several messages, all parts have SplitIdField
+	// with the same value and SplitPartField/SplitPartsField with
+	// part number (starting from 1) and number of parts
+	OversizedSplit
+
+	// message is not sent, it is counted in emitter's Dropped
+	OversizedDrop
+)
+
+// Names of fields added to oversized messages.
+const (
+	TruncatedField  = "golog.truncated"
+	SplitIdField    = "golog.split_id"
+	SplitPartField  = "golog.split_part"
+	SplitPartsField = "golog.split_parts"
+)
+
+// space reserved for growth of payload length and integer fields varints
+const sizeReserve = 16
+
+// truncate cuts payload to make message fit into MAX_MESSAGE_SIZE.
+// It returns false if message is too big even with empty payload.
+func truncate(msg *Message) bool {
+	payload := msg.GetPayload()
+	msg.AddField(TruncatedField, len(payload), "")
+	msg.SortFields()
+
+	excess := msg.Size() - MAX_MESSAGE_SIZE
+	if excess > len(payload) {
+		return false
+	}
+
+	msg.SetPayload(cutUTF8(payload, len(payload)-excess))
+	return true
+}
+
+// sendSplit sends payload in several messages, other values
+// and fields are copied to all of them.
+func (pe *ProtobufEmitter) sendSplit(hm *hekaMessage) error {
+	payload := hm.msg.GetPayload()
+
+	splitId, err := id.Encode(hm.msg.Uuid)
+	if err != nil {
+		return err
+	}
+
+	hm.msg.SetPayload("")
+	hm.msg.AddField(SplitIdField, splitId, "")
+	hm.msg.AddField(SplitPartField, 0, "")
+	hm.msg.AddField(SplitPartsField, 0, "")
+
+	chunkSize := MAX_MESSAGE_SIZE - hm.msg.Size() - sizeReserve
+	if chunkSize <= 0 {
+		atomic.AddInt64(&pe.dropped, 1)
+		return fmt.Errorf("Message fields exceed maximum size %d", MAX_MESSAGE_SIZE)
+	}
+
+	var chunks []string
+	for len(payload) > 0 {
+		chunk := cutUTF8(payload, chunkSize)
+		if chunk == "" {
+			_, size := utf8.DecodeRuneInString(payload)
+			chunk = payload[:size]
+		}
+		chunks = append(chunks, chunk)
+		payload = payload[len(chunk):]
+	}
+
+	part := hm.msg.Fields[len(hm.msg.Fields)-2]
+	parts := hm.msg.Fields[len(hm.msg.Fields)-1]
+	parts.ValueInteger[0] = int64(len(chunks))
+	hm.msg.SortFields()
+
+	for i, chunk := range chunks {
+		if i > 0 {
+			if err := pe.setID(hm); err != nil {
+				return err
+			}
+		}
+
+		part.ValueInteger[0] = int64(i + 1)
+		hm.msg.SetPayload(chunk)
+		if err := pe.write(hm); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// cutUTF8 returns prefix of s not longer than n bytes,
+// which doesn't end in the middle of UTF-8 sequence.
+func cutUTF8(s string, n int) string {
+	if n >= len(s) {
+		return s
+	}
+
+	for n > 0 && !utf8.RuneStart(s[n]) {
+		n--
+	}
+	return s[:n]
+}
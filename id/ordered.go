@@ -0,0 +1,104 @@
+/* This Source Code Form is subject to the terms of the Mozilla Public
+ * License, v. 2.0. If a copy of the MPL was not distributed with this
+ * file, You can obtain one at http://mozilla.org/MPL/2.0/. */
+
+package id
+
+import (
+	"crypto/rand"
+	"encoding/binary"
+	"encoding/hex"
+	"sync"
+	"time"
+)
+
+// Generator generates time-ordered UUIDs (version 7). First 48 bits of ID
+// are Unix time in milliseconds, next 12 bits are counter which starts from
+// random value every millisecond, other bits are random. IDs generated by one
+// generator are strictly increasing even if several IDs are generated within
+// one millisecond or system clock goes backwards.
+type Generator struct {
+	mu      sync.Mutex
+	lastMs  int64
+	counter uint16
+	random  []byte
+}
+
+// random bytes are read in blocks, so most IDs don't need system calls
+const randomBlockSize = 1024
+
+// 12 bits of counter, it starts from value lower than half,
+// so there is space for at least 2048 IDs in millisecond
+const (
+	counterMask  = 0x0fff
+	counterStart = 0x07ff
+)
+
+var defaultGenerator = &Generator{}
+
+// GenerateOrderedBytes generates time-ordered UUID using default generator.
+func GenerateOrderedBytes() ([]byte, error) {
+	u, err := NewOrdered()
+	if err != nil {
+		return nil, err
+	}
+	return u[:], nil
+}
+
+// GenerateOrdered generates time-ordered, non-hyphenated, hex-encoded UUID.
+func GenerateOrdered() (string, error) {
+	bytes, err := GenerateOrderedBytes()
+	if err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(bytes), nil
+}
+
+// Read writes new ID into first 16 bytes of dst, it panics
+// if dst is too small.
+func (g *Generator) Read(dst []byte) error {
+	_ = dst[15]
+
+	g.mu.Lock()
+	defer g.mu.Unlock()
+
+	if len(g.random) < 10 {
+		g.random = make([]byte, randomBlockSize)
+		if _, err := rand.Read(g.random); err != nil {
+			g.random = nil
+			return err
+		}
+	}
+
+	ms := time.Now().UnixNano() / int64(time.Millisecond)
+	if ms > g.lastMs {
+		g.lastMs = ms
+		g.counter = binary.BigEndian.Uint16(g.random) & counterStart
+	} else {
+		// the same millisecond or clock went backwards
+		g.counter++
+		if g.counter > counterMask {
+			// counter is exhausted, time of ID is moved forward
+			g.lastMs++
+			g.counter = binary.BigEndian.Uint16(g.random) & counterStart
+		}
+	}
+
+	binary.BigEndian.PutUint64(dst, uint64(g.lastMs)<<16)
+	binary.BigEndian.PutUint16(dst[6:], 0x7000|g.counter)
+	copy(dst[8:16], g.random[2:10])
+	dst[8] = (dst[8] & 0x3f) | 0x80
+
+	g.random = g.random[10:]
+	return nil
+}
+
+// Time returns time encoded in time-ordered UUID.
+func Time(bytes []byte) (time.Time, error) {
+	if len(bytes) != 16 || bytes[6]>>4 != 7 {
+		return time.Time{}, ErrInvalid
+	}
+
+	ms := int64(binary.BigEndian.Uint64(bytes) >> 16)
+	return time.Unix(ms/1000, ms%1000*int64(time.Millisecond)), nil
+}
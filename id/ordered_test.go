@@ -0,0 +1,113 @@
+/* This Source Code Form is subject to the terms of the Mozilla Public
+ * License, v. 2.0. If a copy of the MPL was not distributed with this
+ * file, You can obtain one at http://mozilla.org/MPL/2.0/. */
+
+package id
+
+import (
+	"bytes"
+	"sync"
+	"testing"
+	"time"
+)
+
+func BenchmarkGenerateOrderedBytes(b *testing.B) {
+	for i := 0; i < b.N; i++ {
+		if _, err := GenerateOrderedBytes(); err != nil {
+			b.Fatalf("Error generating bytes: %s", err)
+		}
+	}
+}
+
+func BenchmarkGeneratorRead(b *testing.B) {
+	g := &Generator{}
+	dst := make([]byte, 16)
+	for i := 0; i < b.N; i++ {
+		if err := g.Read(dst); err != nil {
+			b.Fatalf("Error generating bytes: %s", err)
+		}
+	}
+}
+
+func TestGenerateOrdered(t *testing.T) {
+	before := time.Now().Truncate(time.Millisecond)
+	encoded, err := GenerateOrdered()
+	if err != nil {
+		t.Fatalf("GenerateOrdered() failed: %s", err)
+	}
+	if !Valid(encoded) || len(encoded) != 32 {
+		t.Fatalf("GenerateOrdered() returned invalid ID %q", encoded)
+	}
+
+	bytes, _ := DecodeString(encoded)
+	if bytes[6]>>4 != 7 {
+		t.Errorf("Wrong version of ID %q", encoded)
+	}
+	if bytes[8]>>6 != 2 {
+		t.Errorf("Wrong variant of ID %q", encoded)
+	}
+
+	tm, err := Time(bytes)
+	if err != nil {
+		t.Fatalf("Time() failed: %s", err)
+	}
+	if tm.Before(before) || tm.After(time.Now()) {
+		t.Errorf("Time() of ID %q is %s; want about %s", encoded, tm, before)
+	}
+
+	if _, err := Time(decodedId); err != ErrInvalid {
+		t.Errorf("Time() returned result for random ID: got %#v; want id.ErrInvalid", err)
+	}
+}
+
+func TestGeneratorMonotonic(t *testing.T) {
+	g := &Generator{}
+
+	// more IDs than counter can hold in one millisecond
+	last := make([]byte, 16)
+	for i := 0; i < 10000; i++ {
+		next := make([]byte, 16)
+		if err := g.Read(next); err != nil {
+			t.Fatalf("Read() failed: %s", err)
+		}
+		if bytes.Compare(last, next) >= 0 {
+			t.Fatalf("ID %x isn't greater than previous %x", next, last)
+		}
+		last = next
+	}
+
+	// clock goes backwards
+	g.lastMs += 1000
+	next := make([]byte, 16)
+	g.Read(next)
+	if bytes.Compare(last, next) >= 0 {
+		t.Fatalf("ID %x isn't greater than previous %x", next, last)
+	}
+}
+
+func TestGeneratorConcurrent(t *testing.T) {
+	g := &Generator{}
+	seen := map[string]bool{}
+	var mu sync.Mutex
+	var wg sync.WaitGroup
+
+	for i := 0; i < 4; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for j := 0; j < 1000; j++ {
+				next := make([]byte, 16)
+				g.Read(next)
+
+				mu.Lock()
+				seen[string(next)] = true
+				mu.Unlock()
+			}
+		}()
+	}
+	wg.Wait()
+
+	if len(seen) != 4000 {
+		t.Errorf("Generated %d unique IDs; want 4000", len(seen))
+	}
+}
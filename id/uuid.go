@@ -0,0 +1,284 @@
+/* This Source Code Form is subject to the terms of the Mozilla Public
+ * License, v. 2.0. If a copy of the MPL was not distributed with this
+ * file, You can obtain one at http://mozilla.org/MPL/2.0/. */
+
+package id
+
+import (
+	"crypto/rand"
+	"database/sql/driver"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"strings"
+
+	"gopkg.in/mgo.v2/bson"
+)
+
+// UUID is decoded 16 bytes long UUID. It is encoded as hyphenated,
+// lower-cased hex string in text, JSON and SQL, and as binary
+// with UUID subtype in BSON.
+type UUID [16]byte
+
+// Nil is UUID which has all bits set to zero.
+var Nil UUID
+
+// Variant is layout of UUID bits, defined by its most significant bits
+// of 8th byte.
+type Variant byte
+
+const (
+	// Reserved, NCS backward compatibility.
+	VariantNCS Variant = iota
+	// Layout specified by RFC 4122, used by generated UUIDs.
+	VariantRFC4122
+	// Reserved, Microsoft Corporation backward compatibility.
+	VariantMicrosoft
+	// Reserved for future definition.
+	VariantFuture
+)
+
+// BSON binary subtype of UUID.
+const bsonUUIDKind = 0x04
+
+// New generates random UUID (version 4).
+func New() (UUID, error) {
+	var u UUID
+	if _, err := rand.Read(u[:]); err != nil {
+		return Nil, err
+	}
+	u[6] = (u[6] & 0x0f) | 0x40
+	u[8] = (u[8] & 0x3f) | 0x80
+	return u, nil
+}
+
+// NewOrdered generates time-ordered UUID (version 7) using default generator.
+func NewOrdered() (UUID, error) {
+	var u UUID
+	if err := defaultGenerator.Read(u[:]); err != nil {
+		return Nil, err
+	}
+	return u, nil
+}
+
+// Parse decodes UUID from string. Accepted forms are:
+//
+//	e281b9498a924443b0c85465ba439a76
+//	e281b949-8a92-4443-b0c8-5465ba439a76
+//	{e281b949-8a92-4443-b0c8-5465ba439a76}
+//	urn:uuid:e281b949-8a92-4443-b0c8-5465ba439a76
+//
+// Hex digits can be in any case.
+func Parse(s string) (UUID, error) {
+	var u UUID
+
+	switch {
+	case len(s) == 45 && strings.EqualFold(s[:9], "urn:uuid:"):
+		s = s[9:]
+	case len(s) > 2 && s[0] == '{' && s[len(s)-1] == '}':
+		s = s[1 : len(s)-1]
+	}
+
+	switch len(s) {
+	case 32:
+		for i := range u {
+			b, ok := hexByte(s[2*i], s[2*i+1])
+			if !ok {
+				return Nil, ErrInvalid
+			}
+			u[i] = b
+		}
+	case 36:
+		if s[8] != '-' || s[13] != '-' || s[18] != '-' || s[23] != '-' {
+			return Nil, ErrInvalid
+		}
+		pos := 0
+		for i := range u {
+			if pos == 8 || pos == 13 || pos == 18 || pos == 23 {
+				pos++
+			}
+			b, ok := hexByte(s[pos], s[pos+1])
+			if !ok {
+				return Nil, ErrInvalid
+			}
+			u[i] = b
+			pos += 2
+		}
+	default:
+		return Nil, ErrInvalid
+	}
+
+	return u, nil
+}
+
+// FromBytes makes UUID from 16 bytes long slice.
+func FromBytes(bytes []byte) (UUID, error) {
+	var u UUID
+	if len(bytes) != len(u) {
+		return Nil, ErrInvalid
+	}
+	copy(u[:], bytes)
+	return u, nil
+}
+
+func hexByte(hi, lo byte) (byte, bool) {
+	h, ok := hexValue(hi)
+	if !ok {
+		return 0, false
+	}
+	l, ok := hexValue(lo)
+	if !ok {
+		return 0, false
+	}
+	return h<<4 | l, true
+}
+
+func hexValue(c byte) (byte, bool) {
+	switch {
+	case c >= '0' && c <= '9':
+		return c - '0', true
+	case c >= 'a' && c <= 'f':
+		return c - 'a' + 10, true
+	case c >= 'A' && c <= 'F':
+		return c - 'A' + 10, true
+	}
+	return 0, false
+}
+
+// String returns hyphenated, lower-cased hex string.
+func (u UUID) String() string {
+	return string(u.appendString(make([]byte, 0, 36)))
+}
+
+func (u UUID) appendString(dst []byte) []byte {
+	var buf [36]byte
+	hex.Encode(buf[0:8], u[0:4])
+	buf[8] = '-'
+	hex.Encode(buf[9:13], u[4:6])
+	buf[13] = '-'
+	hex.Encode(buf[14:18], u[6:8])
+	buf[18] = '-'
+	hex.Encode(buf[19:23], u[8:10])
+	buf[23] = '-'
+	hex.Encode(buf[24:], u[10:])
+	return append(dst, buf[:]...)
+}
+
+// Version returns version of UUID, e.g. 4 for random
+// and 7 for time-ordered UUIDs.
+func (u UUID) Version() int {
+	return int(u[6] >> 4)
+}
+
+// Variant returns layout of UUID.
+func (u UUID) Variant() Variant {
+	switch {
+	case u[8]&0x80 == 0:
+		return VariantNCS
+	case u[8]&0xc0 == 0x80:
+		return VariantRFC4122
+	case u[8]&0xe0 == 0xc0:
+		return VariantMicrosoft
+	}
+	return VariantFuture
+}
+
+// IsZero reports whether UUID is Nil.
+func (u UUID) IsZero() bool {
+	return u == Nil
+}
+
+// MarshalText implements encoding.TextMarshaler.
+func (u UUID) MarshalText() ([]byte, error) {
+	return u.appendString(make([]byte, 0, 36)), nil
+}
+
+// UnmarshalText implements encoding.TextUnmarshaler,
+// all forms accepted by Parse can be decoded.
+func (u *UUID) UnmarshalText(text []byte) error {
+	parsed, err := Parse(string(text))
+	if err != nil {
+		return err
+	}
+	*u = parsed
+	return nil
+}
+
+// MarshalJSON implements json.Marshaler.
+func (u UUID) MarshalJSON() ([]byte, error) {
+	buf := make([]byte, 0, 38)
+	buf = append(buf, '"')
+	buf = u.appendString(buf)
+	return append(buf, '"'), nil
+}
+
+// UnmarshalJSON implements json.Unmarshaler, null is decoded as Nil.
+func (u *UUID) UnmarshalJSON(b []byte) error {
+	if string(b) == "null" {
+		*u = Nil
+		return nil
+	}
+
+	var s string
+	if err := json.Unmarshal(b, &s); err != nil {
+		return err
+	}
+	return u.UnmarshalText([]byte(s))
+}
+
+// Scan implements sql.Scanner. UUID can be scanned from string, from
+// 16 raw bytes or from bytes of string, NULL is scanned as Nil.
+func (u *UUID) Scan(src interface{}) error {
+	switch src := src.(type) {
+	case nil:
+		*u = Nil
+		return nil
+	case string:
+		return u.UnmarshalText([]byte(src))
+	case []byte:
+		if len(src) == len(u) {
+			copy(u[:], src)
+			return nil
+		}
+		return u.UnmarshalText(src)
+	}
+	return fmt.Errorf("Can't scan UUID from %T", src)
+}
+
+// Value implements driver.Valuer.
+func (u UUID) Value() (driver.Value, error) {
+	return u.String(), nil
+}
+
+// GetBSON implements bson.Getter.
+func (u UUID) GetBSON() (interface{}, error) {
+	return bson.Binary{Kind: bsonUUIDKind, Data: u[:]}, nil
+}
+
+// SetBSON implements bson.Setter. Besides binary, UUID
+// can be decoded from string, null is decoded as Nil.
+func (u *UUID) SetBSON(raw bson.Raw) error {
+	switch raw.Kind {
+	case 0x0a:
+		*u = Nil
+		return nil
+	case 0x02:
+		var s string
+		if err := raw.Unmarshal(&s); err != nil {
+			return err
+		}
+		return u.UnmarshalText([]byte(s))
+	case 0x05:
+		binary := bson.Binary{}
+		if err := raw.Unmarshal(&binary); err != nil {
+			return err
+		}
+		parsed, err := FromBytes(binary.Data)
+		if err != nil {
+			return err
+		}
+		*u = parsed
+		return nil
+	}
+	return fmt.Errorf("Can't decode UUID from BSON kind %#x", raw.Kind)
+}
@@ -0,0 +1,71 @@
+//go:build go1.18
+// +build go1.18
+
+/* This Source Code Form is subject to the terms of the Mozilla Public
+ * License, v. 2.0. If a copy of the MPL was not distributed with this
+ * file, You can obtain one at http://mozilla.org/MPL/2.0/. */
+
+package id
+
+import (
+	"strings"
+	"testing"
+)
+
+func FuzzParse(f *testing.F) {
+	for s := range parseTests {
+		f.Add(s)
+	}
+	for s := range validTests {
+		f.Add(s)
+	}
+
+	f.Fuzz(func(t *testing.T, s string) {
+		u, err := Parse(s)
+		if Valid(s) != (err == nil) {
+			t.Fatalf("Valid(%q) doesn't agree with Parse()", s)
+		}
+		if err != nil {
+			if err != ErrInvalid {
+				t.Fatalf("Parse(%q) returned %#v; want id.ErrInvalid", s, err)
+			}
+			return
+		}
+
+		// every accepted form has the same hex digits
+		digits := strings.ToLower(strings.Replace(s, "-", "", -1))
+		if !strings.HasSuffix(strings.TrimSuffix(digits, "}"), strings.Replace(u.String(), "-", "", -1)) {
+			t.Fatalf("Parse(%q) decoded %s", s, u)
+		}
+
+		reparsed, err := Parse(u.String())
+		if err != nil || reparsed != u {
+			t.Fatalf("Parse(%q) of String() returned %s, %v; want %s", u.String(), reparsed, err, u)
+		}
+
+		text, _ := u.MarshalText()
+		decoded := UUID{}
+		if err := decoded.UnmarshalText(text); err != nil || decoded != u {
+			t.Fatalf("UnmarshalText(%q) returned %s, %v; want %s", text, decoded, err, u)
+		}
+	})
+}
+
+func FuzzScan(f *testing.F) {
+	f.Add([]byte(encodedId))
+	f.Add(decodedId)
+	f.Add(longId)
+
+	f.Fuzz(func(t *testing.T, src []byte) {
+		u := UUID{}
+		if err := u.Scan(src); err != nil {
+			return
+		}
+
+		value, _ := u.Value()
+		scanned := UUID{}
+		if err := scanned.Scan(value); err != nil || scanned != u {
+			t.Fatalf("Scan(%q) returned %s, %v; want %s", value, scanned, err, u)
+		}
+	})
+}
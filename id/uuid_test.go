@@ -0,0 +1,197 @@
+/* This Source Code Form is subject to the terms of the Mozilla Public
+ * License, v. 2.0. If a copy of the MPL was not distributed with this
+ * file, You can obtain one at http://mozilla.org/MPL/2.0/. */
+
+package id
+
+import (
+	"bytes"
+	"encoding/json"
+	"testing"
+
+	"gopkg.in/mgo.v2/bson"
+)
+
+var decodedUUID = UUID{0xe2, 0x81, 0xb9, 0x49, 0x8a, 0x92, 0x44, 0x43, 0xb0, 0xc8, 0x54, 0x65, 0xba, 0x43, 0x9a, 0x76}
+
+var parseTests = map[string]bool{
+	encodedId:                                       true,
+	hyphenatedId:                                    true,
+	"E281B949-8A92-4443-B0C8-5465BA439A76":          true,
+	"{e281b949-8a92-4443-b0c8-5465ba439a76}":        true,
+	"{e281b9498a924443b0c85465ba439a76}":            true,
+	"urn:uuid:e281b949-8a92-4443-b0c8-5465ba439a76": true,
+	"URN:UUID:e281b949-8a92-4443-b0c8-5465ba439a76": true,
+	"":                                      false,
+	"{}":                                    false,
+	encodedShortId:                          false,
+	encodedLongId:                           false,
+	"{e281b949-8a92-4443-b0c8-5465ba439a76": false,
+	"urn:uuid:e281b9498a924443b0c85465ba439a76":       false,
+	"urn:uuid:{e281b949-8a92-4443-b0c8-5465ba439a76}": false,
+	"e281b949-8a92-4443-b0c8-5465ba439a7g":            false,
+	"e281b949-8a92-4443-b0c8+5465ba439a76":            false,
+}
+
+func TestParse(t *testing.T) {
+	for s, valid := range parseTests {
+		u, err := Parse(s)
+		if !valid {
+			if err != ErrInvalid {
+				t.Errorf("Parse(%q) returned result for invalid UUID: got %#v; want id.ErrInvalid", s, err)
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("Parse(%q) failed: %s", s, err)
+		} else if u != decodedUUID {
+			t.Errorf("Parse(%q) decoded UUID incorrectly: got %#v; want %#v", s, u, decodedUUID)
+		}
+	}
+}
+
+func TestUUIDFields(t *testing.T) {
+	if s := decodedUUID.String(); s != hyphenatedId {
+		t.Errorf("String() returned %q; want %q", s, hyphenatedId)
+	}
+	if v := decodedUUID.Version(); v != 4 {
+		t.Errorf("Version() returned %d; want 4", v)
+	}
+	if v := decodedUUID.Variant(); v != VariantRFC4122 {
+		t.Errorf("Variant() returned %d; want VariantRFC4122", v)
+	}
+	if decodedUUID.IsZero() || !Nil.IsZero() {
+		t.Error("IsZero() returned wrong result")
+	}
+
+	for b, variant := range map[byte]Variant{
+		0x00: VariantNCS, 0x7f: VariantNCS, 0x80: VariantRFC4122, 0xbf: VariantRFC4122,
+		0xc0: VariantMicrosoft, 0xdf: VariantMicrosoft, 0xe0: VariantFuture, 0xff: VariantFuture,
+	} {
+		u := UUID{}
+		u[8] = b
+		if v := u.Variant(); v != variant {
+			t.Errorf("Variant() of %#x returned %d; want %d", b, v, variant)
+		}
+	}
+
+	u, err := NewOrdered()
+	if err != nil {
+		t.Fatalf("NewOrdered() failed: %s", err)
+	}
+	if u.Version() != 7 || u.Variant() != VariantRFC4122 {
+		t.Errorf("NewOrdered() returned UUID %s of version %d and variant %d", u, u.Version(), u.Variant())
+	}
+}
+
+func TestUUIDJSON(t *testing.T) {
+	type doc struct {
+		ID    UUID  `json:"id"`
+		Other *UUID `json:"other"`
+	}
+
+	b, err := json.Marshal(doc{ID: decodedUUID})
+	if err != nil {
+		t.Fatalf("Marshal() failed: %s", err)
+	}
+	if string(b) != `{"id":"`+hyphenatedId+`","other":null}` {
+		t.Errorf("Marshal() returned %s", b)
+	}
+
+	decoded := doc{}
+	if err := json.Unmarshal([]byte(`{"id":"urn:uuid:`+hyphenatedId+`","other":null}`), &decoded); err != nil {
+		t.Fatalf("Unmarshal() failed: %s", err)
+	}
+	if decoded.ID != decodedUUID || decoded.Other != nil {
+		t.Errorf("Unmarshal() decoded %#v", decoded)
+	}
+
+	// UUID is used as map key
+	m := map[UUID]int{}
+	if err := json.Unmarshal([]byte(`{"`+encodedId+`":1}`), &m); err != nil || m[decodedUUID] != 1 {
+		t.Errorf("Unmarshal() of map failed: %#v %v", m, err)
+	}
+
+	for _, invalid := range []string{`{"id":"x"}`, `{"id":1}`} {
+		if err := json.Unmarshal([]byte(invalid), &decoded); err == nil {
+			t.Errorf("Unmarshal(%s) didn't fail", invalid)
+		}
+	}
+}
+
+func TestUUIDSQL(t *testing.T) {
+	value, err := decodedUUID.Value()
+	if err != nil || value != hyphenatedId {
+		t.Errorf("Value() returned %#v, %v", value, err)
+	}
+
+	for _, src := range []interface{}{hyphenatedId, []byte(encodedId), decodedId} {
+		u := UUID{}
+		if err := u.Scan(src); err != nil {
+			t.Errorf("Scan(%#v) failed: %s", src, err)
+		} else if u != decodedUUID {
+			t.Errorf("Scan(%#v) decoded %s", src, u)
+		}
+	}
+
+	u := decodedUUID
+	if err := u.Scan(nil); err != nil || !u.IsZero() {
+		t.Errorf("Scan(nil) returned %s, %v", u, err)
+	}
+	for _, src := range []interface{}{42, shortId, "x"} {
+		if err := u.Scan(src); err == nil {
+			t.Errorf("Scan(%#v) didn't fail", src)
+		}
+	}
+}
+
+func TestUUIDBSON(t *testing.T) {
+	b, err := bson.Marshal(bson.M{"_id": decodedUUID})
+	if err != nil {
+		t.Fatalf("Marshal() failed: %s", err)
+	}
+
+	raw := bson.M{}
+	bson.Unmarshal(b, &raw)
+	binary, ok := raw["_id"].(bson.Binary)
+	if !ok || binary.Kind != 0x04 || !bytes.Equal(binary.Data, decodedId) {
+		t.Errorf("UUID is encoded as %#v", raw["_id"])
+	}
+
+	doc := struct {
+		ID UUID `bson:"_id"`
+	}{}
+	if err := bson.Unmarshal(b, &doc); err != nil || doc.ID != decodedUUID {
+		t.Errorf("Unmarshal() decoded %s, %v", doc.ID, err)
+	}
+
+	b, _ = bson.Marshal(bson.M{"_id": hyphenatedId})
+	if err := bson.Unmarshal(b, &doc); err != nil || doc.ID != decodedUUID {
+		t.Errorf("Unmarshal() of string decoded %s, %v", doc.ID, err)
+	}
+
+	b, _ = bson.Marshal(bson.M{"_id": 42})
+	if err := bson.Unmarshal(b, &doc); err == nil {
+		t.Error("Unmarshal() of number didn't fail")
+	}
+}
+
+func TestDecodeShortDestination(t *testing.T) {
+	if err := Decode(encodedId, make([]byte, 15)); err != ErrInvalid {
+		t.Errorf("Decode() into short slice: got %#v; want id.ErrInvalid", err)
+	}
+}
+
+func BenchmarkParse(b *testing.B) {
+	for i := 0; i < b.N; i++ {
+		if _, err := Parse(hyphenatedId); err != nil {
+			b.Fatalf("Error parsing UUID: %s", err)
+		}
+	}
+}
+
+func BenchmarkString(b *testing.B) {
+	for i := 0; i < b.N; i++ {
+		_ = decodedUUID.String()
+	}
+}
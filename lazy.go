@@ -0,0 +1,39 @@
+package golog
+
+// Lazy is value of message or log data which is expensive to compute.
+// It is evaluated only if log is made and some appender receives it,
+// so it costs nothing when level is disabled:
+//
+//	logger.Debug("state", golog.LazyFunc(func() interface{} {
+//		return dumpState()
+//	}))
+type Lazy interface {
+	// value which is logged instead of lazy value
+	LazyValue() interface{}
+}
+
+// LazyFunc is function used as lazy value.
+type LazyFunc func() interface{}
+
+// Calling function.
+func (f LazyFunc) LazyValue() interface{} {
+	return f()
+}
+
+// copies data for appenders and replaces lazy values with their
+// values, caller's slice doesn't escape, so data given to disabled
+// levels doesn't have to be allocated
+func copyData(data []interface{}) []interface{} {
+	if len(data) == 0 {
+		return nil
+	}
+
+	copied := make([]interface{}, len(data))
+	for i, item := range data {
+		if lazy, ok := item.(Lazy); ok {
+			item = lazy.LazyValue()
+		}
+		copied[i] = item
+	}
+	return copied
+}
@@ -0,0 +1,170 @@
+package golog
+
+import (
+	"fmt"
+	"os"
+	"strings"
+)
+
+// Name of environment variable with level spec which is applied
+// when package is initialized (look at ApplyLevelSpec).
+const LevelEnv = "GOLOG_LEVEL"
+
+// one entry of level spec
+type levelRule struct {
+	// logger name, or prefix of names if pattern ends with *
+	pattern string
+	prefix  bool
+
+	level LogLevel
+	off   bool
+}
+
+var (
+	// rules of last applied spec
+	levelRules []levelRule
+	// error of spec from environment
+	levelEnvErr error
+)
+
+// ApplyLevelSpec sets levels of existing loggers and of loggers created
+// later with GetLogger. Spec is comma separated list of rules:
+//
+//	info,payments=debug,github.com/someuser/*=error,noisy=off
+//
+// Rule without name is default level of all loggers, name ending with
+// * matches all loggers with given prefix (* is allowed only at the end),
+// and level "off" disables loggers. Loggers disabled with Disable are not
+// enabled by spec. Logger gets level of the most specific matching rule: exact
+// name wins over patterns, and longer pattern wins over shorter one.
+// If several rules are equally specific, the last one wins. Loggers
+// which don't match any rule get back level they had before any spec
+// was applied to them.
+//
+// Spec replaces previously applied one. If it is invalid, error
+// is returned and nothing is changed.
+func ApplyLevelSpec(spec string) error {
+	rules, err := parseLevelSpec(spec)
+	if err != nil {
+		return err
+	}
+
+	levelRules = rules
+	for _, logger := range loggers {
+		applyLevelRules(logger)
+	}
+	return nil
+}
+
+func parseLevelSpec(spec string) ([]levelRule, error) {
+	var rules []levelRule
+
+	for _, entry := range strings.Split(spec, ",") {
+		entry = strings.TrimSpace(entry)
+		if entry == "" {
+			continue
+		}
+
+		rule := levelRule{}
+		levelName := entry
+		if i := strings.LastIndex(entry, "="); i >= 0 {
+			rule.pattern = strings.TrimSpace(entry[:i])
+			levelName = strings.TrimSpace(entry[i+1:])
+			if rule.pattern == "" {
+				return nil, fmt.Errorf("Missing logger name in level spec entry %q", entry)
+			}
+		}
+
+		// rule without name is the same as lone *
+		if rule.pattern == "" || strings.HasSuffix(rule.pattern, "*") {
+			rule.pattern = strings.TrimSuffix(rule.pattern, "*")
+			rule.prefix = true
+		}
+		if strings.Contains(rule.pattern, "*") {
+			return nil, fmt.Errorf("Wildcard is allowed only at the end of logger name in level spec entry %q", entry)
+		}
+
+		if strings.EqualFold(levelName, "off") {
+			rule.off = true
+		} else {
+			level, err := ParseLevel(levelName)
+			if err != nil {
+				return nil, err
+			}
+			rule.level = level
+		}
+		rules = append(rules, rule)
+	}
+	return rules, nil
+}
+
+// sets level of logger according to the most specific rule
+func applyLevelRules(logger *Logger) {
+	var best *levelRule
+	for i := range levelRules {
+		rule := &levelRules[i]
+		if !rule.matches(logger.Name) {
+			continue
+		}
+		if best == nil || !best.moreSpecific(rule) {
+			best = rule
+		}
+	}
+
+	if best == nil {
+		// spec which set level of logger was replaced
+		if logger.bySpec {
+			logger.Level = logger.baseLevel
+			logger.specOff = false
+			logger.bySpec = false
+		}
+		return
+	}
+
+	if !logger.bySpec {
+		logger.baseLevel = logger.Level
+		logger.bySpec = true
+	}
+
+	// loggers disabled with Disable stay disabled
+	logger.specOff = best.off
+	if !best.off {
+		logger.Level = best.level
+	}
+}
+
+func (r *levelRule) matches(name string) bool {
+	if r.prefix {
+		return strings.HasPrefix(name, r.pattern)
+	}
+	return name == r.pattern
+}
+
+// exact name is more specific than any pattern,
+// longer patterns are more specific than shorter
+func (r *levelRule) moreSpecific(other *levelRule) bool {
+	if r.prefix != other.prefix {
+		return !r.prefix
+	}
+	return len(r.pattern) > len(other.pattern)
+}
+
+// LevelEnvError returns error of invalid level spec in LevelEnv
+// environment variable, the spec is ignored then.
+func LevelEnvError() error {
+	return levelEnvErr
+}
+
+// applies spec from environment, invalid spec is ignored
+func applyLevelEnv() {
+	levelEnvErr = nil
+
+	spec := os.Getenv(LevelEnv)
+	if spec == "" {
+		return
+	}
+
+	if err := ApplyLevelSpec(spec); err != nil {
+		levelEnvErr = fmt.Errorf("Invalid %s: %s", LevelEnv, err)
+	}
+}
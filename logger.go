@@ -2,8 +2,9 @@ package golog
 
 import (
 	"fmt"
-	"os"
+	"runtime"
 	"strings"
+	"sync"
 	"time"
 )
 
@@ -36,18 +37,22 @@ func (l LogLevel) String() string {
 	return levelNames[l]
 }
 
-var (
-	// limit when logger name will be normalized
-	// normalized names are shown in console using stdout appender
-	maxnamelen = 20
-	curnamelen = 7
-
-	// supported name separators
-	separators []byte = []byte{'/', '.', '-'}
-)
+// Returns level with given name, names are case insensitive.
+func ParseLevel(name string) (LogLevel, error) {
+	for level, levelName := range levelNames {
+		if strings.EqualFold(name, levelName) {
+			return level, nil
+		}
+	}
+	return 0, fmt.Errorf("Unknown log level %q", name)
+}
 
 // Representing one Log instance
 type Log struct {
+	// unique time-ordered ID of log (UUID version 7), appenders
+	// can use it to recognize log which was delivered several times
+	ID []byte `json:"id"`
+
 	// date and time of log
 	Time time.Time `json:"time"`
 
@@ -73,12 +78,25 @@ type Log struct {
 // Logger can have multiple appenders, it can enable it,
 // or disable it. Also you can define level which will be specific to this logger.
 type Logger struct {
+	// guards appenders, list is never modified in place,
+	// so logs can be sent to its copy without holding lock
+	mu sync.RWMutex
+
 	// list of appenders
 	appenders []Appender
 
 	// is logged disabled
 	disabled bool
 
+	// is logger disabled by level spec, it is kept apart
+	// from disabled, so spec doesn't enable disabled loggers
+	specOff bool
+
+	// level which logger had before level spec changed it, it's
+	// restored when applied spec doesn't match logger anymore
+	baseLevel LogLevel
+	bySpec    bool
+
 	// name of logger
 	// logger name will be shown in stdout appender output
 	// also it can be used to enable/disable logger
@@ -91,123 +109,102 @@ type Logger struct {
 	// appender should panic. This also depends on appender implementation,
 	// so appender can decide to ignore or to accept information in this flag
 	DoPanic bool `json:"-"`
+
+	// source of time, pid and IDs of logs, it also exits
+	// process after fatal logs, SystemEnvironment is used if it isn't set
+	Environment Environment `json:"-"`
+}
+
+// Reports whether logs with given level are made, so expensive
+// arguments can be prepared only if they are needed:
+//
+//	if logger.Enabled(golog.DEBUG) {
+//		logger.Debug("state", dumpState())
+//	}
+func (l *Logger) Enabled(lvl LogLevel) bool {
+	return !l.disabled && !l.specOff && lvl <= l.Level
 }
 
 // Making and sending log entry to appenders if log level is appropriate.
+// Message can be func() string or Lazy, it is evaluated (as well as
+// Lazy values in data) only if log is made and logger has appenders.
 func (l *Logger) Log(lvl LogLevel, msg interface{}, data []interface{}) {
-	if l.disabled {
+	if !l.Enabled(lvl) {
 		return
 	}
 
-	if lvl <= l.Level {
-		log := Log{
-			Time:    time.Now(),
-			Message: l.toString(msg),
-			Level:   lvl,
-			Data:    data,
-			Logger:  l,
-			Pid:     os.Getpid(),
-		}
+	l.mu.RLock()
+	appenders := l.appenders
+	l.mu.RUnlock()
 
-		for _, appender := range l.appenders {
-			appender.Append(log)
-		}
-	}
-}
-
-func (l *Logger) toString(object interface{}) string {
-	return fmt.Sprintf("%v", object)
-}
-
-// method will normalize names if they are too big or too short
-// normal name length if defined by namelen variable
-func (l *Logger) normalizeName() {
-	length := len(l.Name)
-
-	// name is ok as it is
-	if length == maxnamelen || length == curnamelen {
+	if len(appenders) == 0 {
 		return
 	}
 
-	// name is too short, add some spaces
-	if length < curnamelen {
-		l.normalizeNameLen()
-		return
+	env := l.env()
+
+	// log without ID is delivered anyway, error can only
+	// happen if system random source is broken
+	logID, _ := env.NewID()
+
+	// entries aren't pooled: log is passed to appenders by value, so it
+	// stays on stack, and appenders can keep it (and its ID and data)
+	// after Append returns, e.g. recorder of logtest or batching Mongo
+	// appender, so neither log nor ID can be reused
+	log := Log{
+		ID:      logID,
+		Time:    env.Now(),
+		Message: l.toString(msg),
+		Level:   lvl,
+		Data:    copyData(data),
+		Logger:  l,
+		Pid:     env.Pid(),
 	}
 
-	// name is too long
-	// do best to normalize it
-
-	var (
-		normalized string
-		parts      []string
-		separator  byte
-	)
-
-	// try split long name using different separators
-	// this first one which can split name into smaller parts will be used
-	for _, sep := range separators {
-		parts = strings.Split(l.Name, string(sep))
-		if len(parts) > 1 {
-			separator = sep
-			break
-		}
+	for _, appender := range appenders {
+		appender.Append(log)
 	}
+}
 
-	// if we sucesufully splitted string into multiple parts
-	if len(parts) > 1 {
-		appendSeparator := true
-
-		for i, str := range parts {
-			// if part length is bigger than zero
-			switch len(str) {
-			case 0:
-				appendSeparator = false
-				break
-			case 1:
-				normalized += str[:1]
-				break
-			case 2:
-				normalized += str[:2]
-				break
-			default:
-				normalized += str[:3]
-				break
-			}
-
-			if appendSeparator && (i != (len(parts) - 1)) {
-				normalized += string(separator)
-			}
+// Caller returns location of code which called one of Logger methods.
+// Appenders can use it to show where log was made, location is known
+// only if appender is called synchronously from Logger.Log.
+func Caller() (file string, line int, function string, ok bool) {
+	pcs := make([]uintptr, 32)
+	frames := runtime.CallersFrames(pcs[:runtime.Callers(2, pcs)])
+
+	inLogger := false
+	for {
+		frame, more := frames.Next()
+		if strings.HasPrefix(frame.Function, "github.com/ildus/golog.(*Logger).") {
+			inLogger = true
+		} else if inLogger {
+			return frame.File, frame.Line, frame.Function, true
 		}
 
-		// if still to long
-		if len(normalized) > maxnamelen {
-			normalized = normalized[:maxnamelen]
-		}
-	} else {
-		length := len(l.Name)
-		if length > maxnamelen {
-			normalized = l.Name[:maxnamelen]
-		} else {
-			normalized = l.Name[0:length]
+		if !more {
+			return "", 0, "", false
 		}
 	}
+}
 
-	l.Name = normalized
-	if len(normalized) >= curnamelen {
-		curnamelen = len(normalized)
-	} else {
-		l.normalizeNameLen()
+func (l *Logger) env() Environment {
+	if l.Environment == nil {
+		return SystemEnvironment
 	}
+	return l.Environment
 }
 
-// if name is still to short we will add spaces
-func (l *Logger) normalizeNameLen() {
-	length := len(l.Name)
-	missing := curnamelen - length
-	for i := 0; i < missing; i++ {
-		l.Name += " "
+func (l *Logger) toString(object interface{}) string {
+	switch msg := object.(type) {
+	case string:
+		return msg
+	case func() string:
+		return msg()
+	case Lazy:
+		object = msg.LazyValue()
 	}
+	return fmt.Sprintf("%v", object)
 }
 
 // Making log with DEBUG level.
@@ -233,33 +230,74 @@ func (l *Logger) Error(msg interface{}, data ...interface{}) {
 // Making log with CRITICAL level.
 func (l *Logger) Fatal(msg interface{}, data ...interface{}) {
 	l.Log(CRITICAL, msg, data)
-	osExit(1)
+	l.env().Exit(1)
 }
 
 // Making formatted log with DEBUG level.
 func (l *Logger) Debugf(msg string, params ...interface{}) {
-	l.Log(DEBUG, fmt.Sprintf(msg, params...), nil)
+	if l.Enabled(DEBUG) {
+		l.Log(DEBUG, fmt.Sprintf(msg, params...), nil)
+	}
 }
 
 // Making formatted log with INFO level.
 func (l *Logger) Infof(msg string, params ...interface{}) {
-	l.Log(INFO, fmt.Sprintf(msg, params...), nil)
+	if l.Enabled(INFO) {
+		l.Log(INFO, fmt.Sprintf(msg, params...), nil)
+	}
 }
 
 // Making formatted log with WARN level.
 func (l *Logger) Warnf(msg string, params ...interface{}) {
-	l.Log(WARNING, fmt.Sprintf(msg, params...), nil)
+	if l.Enabled(WARNING) {
+		l.Log(WARNING, fmt.Sprintf(msg, params...), nil)
+	}
 }
 
 // Making formatted log with ERROR level.
 func (l *Logger) Errorf(msg string, params ...interface{}) {
-	l.Log(ERROR, fmt.Sprintf(msg, params...), nil)
+	if l.Enabled(ERROR) {
+		l.Log(ERROR, fmt.Sprintf(msg, params...), nil)
+	}
 }
 
 // Making formatted log with CRITICAL level.
 func (l *Logger) Fatalf(msg string, params ...interface{}) {
-	l.Log(CRITICAL, fmt.Sprintf(msg, params...), nil)
-	osExit(1)
+	if l.Enabled(CRITICAL) {
+		l.Log(CRITICAL, fmt.Sprintf(msg, params...), nil)
+	}
+	l.env().Exit(1)
+}
+
+// Making log with DEBUG level, message is made by fn
+// only if log is made.
+func (l *Logger) DebugFn(fn func() string, data ...interface{}) {
+	l.Log(DEBUG, fn, data)
+}
+
+// Making log with INFO level, message is made by fn
+// only if log is made.
+func (l *Logger) InfoFn(fn func() string, data ...interface{}) {
+	l.Log(INFO, fn, data)
+}
+
+// Making log with WARN level, message is made by fn
+// only if log is made.
+func (l *Logger) WarnFn(fn func() string, data ...interface{}) {
+	l.Log(WARNING, fn, data)
+}
+
+// Making log with ERROR level, message is made by fn
+// only if log is made.
+func (l *Logger) ErrorFn(fn func() string, data ...interface{}) {
+	l.Log(ERROR, fn, data)
+}
+
+// Making log with CRITICAL level, message is made by fn
+// only if log is made.
+func (l *Logger) FatalFn(fn func() string, data ...interface{}) {
+	l.Log(CRITICAL, fn, data)
+	l.env().Exit(1)
 }
 
 // When you want to send logs to another appender,
@@ -267,7 +305,11 @@ func (l *Logger) Fatalf(msg string, params ...interface{}) {
 // Method is expecting appender instance to be passed
 // to this method. At the end passed appender will receive logs
 func (l *Logger) Enable(appender Appender) {
-	l.appenders = append(l.appenders, appender)
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	// capacity is limited, so new list is allocated
+	l.appenders = append(l.appenders[:len(l.appenders):len(l.appenders)], appender)
 }
 
 // If you want to disable logs from some appender you can use this method.
@@ -289,19 +331,27 @@ func (l *Logger) Disable(target interface{}) {
 		return
 	}
 
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	index := -1
 	for i, app := range l.appenders {
-		// if we can find the same appender reference
-		// or we can extract and match id from appender
-		// or we can match received id string argument with one of appender's id
-		if (appender != nil && (app == appender || appender.Id() == app.Id())) || id == app.Id() {
-			var toAppend []Appender
-
-			if len(l.appenders) >= i+1 {
-				toAppend = l.appenders[i+1:]
-			}
-
-			l.appenders = append(l.appenders[:i], toAppend...)
-			return
+		// the same appender reference is preferred, so one
+		// of several appenders with the same ID can be disabled
+		if appender != nil && app == appender {
+			index = i
+			break
+		}
+
+		// otherwise first appender with matching id is disabled
+		if index < 0 && ((appender != nil && appender.Id() == app.Id()) || id == app.Id()) {
+			index = i
 		}
 	}
+
+	if index >= 0 {
+		appenders := make([]Appender, 0, len(l.appenders)-1)
+		appenders = append(appenders, l.appenders[:index]...)
+		l.appenders = append(appenders, l.appenders[index+1:]...)
+	}
 }
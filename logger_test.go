@@ -1,8 +1,12 @@
 package golog
 
 import (
+	"bytes"
+	"errors"
 	"github.com/stretchr/testify/assert"
+	"io/ioutil"
 	"testing"
+	"time"
 )
 
 type testAppender struct {
@@ -10,10 +14,12 @@ type testAppender struct {
 	errorCount int
 	warnCount  int
 	msg        string
+	ids        [][]byte
 }
 
 func (s *testAppender) Append(log Log) {
 	s.msg = log.Message
+	s.ids = append(s.ids, log.ID)
 	s.count += 1
 
 	if log.Level == WARNING {
@@ -25,9 +31,32 @@ func (s *testAppender) Append(log Log) {
 	}
 }
 
+// deterministic environment which records exits
+type testEnvironment struct {
+	ids   byte
+	exits []int
+}
+
+func (e *testEnvironment) Now() time.Time {
+	return time.Date(2009, 11, 10, 23, 0, 0, 0, time.UTC)
+}
+
+func (e *testEnvironment) Pid() int {
+	return 42
+}
+
+func (e *testEnvironment) NewID() ([]byte, error) {
+	e.ids++
+	return []byte{0, 0, 0, 0, 0, 0, 0x70, 0, 0x80, 0, 0, 0, 0, 0, 0, e.ids}, nil
+}
+
+func (e *testEnvironment) Exit(code int) {
+	e.exits = append(e.exits, code)
+}
+
 func cleanupTest() {
-	useStdFuncs()
 	loggers = map[string]*Logger{}
+	levelRules = nil
 
 	Default = &Logger{
 		Name:  "default",
@@ -100,6 +129,19 @@ func TestDisableById(t *testing.T) {
 	assert.Exactly(t, 0, ta.count)
 }
 
+func TestDisableSameId(t *testing.T) {
+	defer cleanupTest()
+	first, second := &testAppender{}, &testAppender{}
+
+	Default.Enable(first)
+	Default.Enable(second)
+
+	Default.Disable(second)
+	Default.Info("some msg")
+	assert.Exactly(t, 1, first.count)
+	assert.Exactly(t, 0, second.count)
+}
+
 func TestDisableInvalid(t *testing.T) {
 	defer cleanupTest()
 
@@ -111,9 +153,11 @@ func TestDisableInvalid(t *testing.T) {
 }
 
 func TestLogCalls(t *testing.T) {
-	mockFuncs()
 	defer cleanupTest()
 
+	env := &testEnvironment{}
+	Default.Environment = env
+
 	defer func() {
 		if r := recover(); r != nil {
 		}
@@ -138,6 +182,7 @@ func TestLogCalls(t *testing.T) {
 	Default.Fatal("some msg")
 
 	assert.Exactly(t, 10, ta.count)
+	assert.Equal(t, []int{1, 1}, env.exits)
 
 	ta.msg = ""
 	Default.Debugf("some %s", "message")
@@ -158,6 +203,29 @@ func TestLogCalls(t *testing.T) {
 	ta.msg = ""
 	Default.Errorf("some %s message", "panic")
 	assert.Equal(t, "some panic message", ta.msg)
+
+	Default.Fatalf("some %s", "message")
+	assert.Equal(t, []int{1, 1, 1}, env.exits)
+}
+
+func TestLogID(t *testing.T) {
+	defer cleanupTest()
+
+	ta := &testAppender{}
+	logger := &Logger{Name: "ids", Level: DEBUG}
+	logger.Enable(ta)
+
+	for i := 0; i < 100; i++ {
+		logger.Info("some msg")
+	}
+
+	assert.Len(t, ta.ids, 100)
+	for i, logID := range ta.ids {
+		assert.Len(t, logID, 16)
+		if i > 0 {
+			assert.True(t, bytes.Compare(ta.ids[i-1], logID) < 0, "IDs are ordered")
+		}
+	}
 }
 
 func TestLogCallsWithLevel(t *testing.T) {
@@ -200,43 +268,331 @@ func TestLogCallsWithLevel(t *testing.T) {
 	assert.Exactly(t, 8, ta.count)
 }
 
-func normalizeNameLenInTest(name string) string {
-	length := len(name)
-	missing := curnamelen - length
+func TestEnabled(t *testing.T) {
+	logger := &Logger{Name: "enabled", Level: WARNING}
+
+	assert.True(t, logger.Enabled(ERROR))
+	assert.True(t, logger.Enabled(WARNING))
+	assert.False(t, logger.Enabled(DEBUG))
+
+	logger.disabled = true
+	assert.False(t, logger.Enabled(ERROR))
+}
+
+func TestLazyMessage(t *testing.T) {
+	ta := &testAppender{}
+	logger := &Logger{Name: "lazy", Level: INFO}
+	logger.Enable(ta)
+
+	calls := 0
+	fn := func() string {
+		calls++
+		return "lazy message"
+	}
+
+	logger.DebugFn(fn)
+	assert.Equal(t, 0, calls, "message is not made for disabled level")
+
+	logger.InfoFn(fn)
+	assert.Equal(t, 1, calls)
+	assert.Equal(t, "lazy message", ta.msg)
+
+	logger.Info(LazyFunc(func() interface{} { return 42 }))
+	assert.Equal(t, "42", ta.msg)
+
+	silent := &Logger{Name: "silent", Level: DEBUG}
+	silent.InfoFn(fn)
+	assert.Equal(t, 1, calls, "message is not made without appenders")
+}
+
+type dataAppender struct {
+	data []interface{}
+}
+
+func (d *dataAppender) Append(log Log) {
+	d.data = log.Data
+}
+
+func (d *dataAppender) Id() string {
+	return "github.com/ildus/golog/test/data"
+}
+
+func TestLazyData(t *testing.T) {
+	da := &dataAppender{}
+	logger := &Logger{Name: "lazydata", Level: INFO}
+	logger.Enable(da)
+
+	calls := 0
+	lazy := LazyFunc(func() interface{} {
+		calls++
+		return "value"
+	})
+
+	logger.Debug("msg", 1, lazy)
+	assert.Equal(t, 0, calls, "data is not evaluated for disabled level")
+
+	data := []interface{}{1, lazy}
+	logger.Log(INFO, "msg", data)
+	assert.Equal(t, 1, calls)
+	assert.Equal(t, []interface{}{1, "value"}, da.data)
+	_, ok := data[1].(LazyFunc)
+	assert.True(t, ok, "caller's data is not modified")
+}
+
+func TestNameIsKept(t *testing.T) {
+	names := []string{
+		"s.o.m.e.r.e.a.l.l.y.l.o.n.g.n.a.m.e.t.e.s.t.n.a.m.e.",
+		"github.com/ildus/golog",
+		"main",
+	}
+	for _, name := range names {
+		l := GetLogger(name)
+		l.Debug(l.Name)
+		assert.Equal(t, name, l.Name)
+	}
+
+	// new loggers don't change names of existing ones
+	assert.Equal(t, "main", GetLogger("main").Name)
+	assert.Equal(t, "github.com/ildus/golog", GetLogger("github.com/ildus/golog").Name)
+}
+
+func TestNameDisable(t *testing.T) {
+	GetLogger("github.com/ildus/golog/disabled")
+	GetLogger("a.much.longer.name.of.another.logger")
+
+	Disable("github.com/ildus/golog/disabled")
+	defer Enable("github.com/ildus/golog/disabled")
+
+	assert.True(t, GetLogger("github.com/ildus/golog/disabled").disabled)
+	assert.Equal(t, "github.com/ildus/golog/disabled", GetLogger("github.com/ildus/golog/disabled").Name)
+}
+
+func TestNameJSON(t *testing.T) {
+	buf := new(bytes.Buffer)
+	logger := &Logger{Name: "github.com/ildus/golog", Level: DEBUG}
+	logger.Enable(NewConsole(buf, Conf{"format": "json"}))
 
-	for i := 0; i < missing; i++ {
-		name += " "
+	logger.Info("json")
+
+	assert.Contains(t, buf.String(), `"name":"github.com/ildus/golog"`)
+}
+
+func TestSegmentLayout(t *testing.T) {
+	layout := NewSegmentLayout(20)
+	name := func(name string) string {
+		return string(layout.AppendName(nil, name))
+	}
+
+	assert.Equal(t, "main   ", name("main"))
+	assert.Equal(t, "s.o.m.e.r.e.a.l.l.y.", name("s.o.m.e.r.e.a.l.l.y.l.o.n.g.n.a.m.e.t.e.s.t.n.a.m.e."))
+	assert.Equal(t, "git/ild/gol         ", name("github.com/ildus/golog"))
+	assert.Equal(t, "git.com.ild.gol     ", name("github.com.ildus.golog"))
+	assert.Equal(t, "abcdefghijklmnopqrst", name("abcdefghijklmnopqrstuvwxyz"))
+	assert.Equal(t, "main                ", name("main"))
+}
+
+func TestLogbackLayout(t *testing.T) {
+	layout := LogbackLayout{Width: 10}
+	name := func(name string) string {
+		return string(layout.AppendName(nil, name))
+	}
+
+	assert.Equal(t, "main      ", name("main"))
+	assert.Equal(t, "g/i/golog ", name("github.com/ildus/golog"))
+	assert.Equal(t, "g.c.i.golog", name("github.com.ildus.golog"))
+	assert.Equal(t, "a.b.golog ", name("aaa.bbb.golog"))
+	assert.Equal(t, "a.bbb.log ", name("aaa.bbb.log"))
+	assert.Equal(t, "averylongname", name("averylongname"))
+
+	// cutting stops when name fits
+	layout.Width = 20
+	assert.Equal(t, "g/someuser/somelib  ", name("github.com/someuser/somelib"))
+}
+
+func TestNameLayoutConf(t *testing.T) {
+	logger := &Logger{Name: "github.com/ildus/golog", Level: DEBUG}
+	layouts := map[string]string{
+		"":         "git/ild/gol ",
+		"segments": "git/ild/gol ",
+		"full":     "github.com/ildus/golog ",
+		"{12}":     "g/i/golog    ",
+		"wrong":    "git/ild/gol ",
+	}
+	for value, name := range layouts {
+		buf := new(bytes.Buffer)
+		NewConsole(buf, Conf{"color": "never", "name": value}).Append(Log{
+			Time:    time.Now(),
+			Level:   INFO,
+			Message: "layout",
+			Logger:  logger,
+		})
+		assert.Equal(t, "INFO "+name+"[", buf.String()[:len("INFO "+name+"[")], value)
+	}
+}
+
+func TestParseLevel(t *testing.T) {
+	level, err := ParseLevel("warning")
+	assert.Nil(t, err)
+	assert.Equal(t, WARNING, level)
+
+	level, err = ParseLevel("EMERGENCY")
+	assert.Nil(t, err)
+	assert.Equal(t, EMERGENCY, level)
+
+	_, err = ParseLevel("verbose")
+	assert.NotNil(t, err)
+}
+
+func TestStdoutEnvironment(t *testing.T) {
+	buf := new(bytes.Buffer)
+	logger := &Logger{Name: "golden", Level: DEBUG, Environment: &testEnvironment{}}
+	logger.Enable(NewConsole(buf, Conf{"color": "never"}))
+	ta := &testAppender{}
+	logger.Enable(ta)
+
+	logger.Info("first", map[string]int{"size": 10})
+	logger.Error("second")
+
+	assert.Equal(t, "INFO golden  [2009-11-10 23:00:00]: first map[size:10]\n"+
+		"ERRO golden  [2009-11-10 23:00:00]: second\n", buf.String())
+	assert.Equal(t, [][]byte{
+		{0, 0, 0, 0, 0, 0, 0x70, 0, 0x80, 0, 0, 0, 0, 0, 0, 1},
+		{0, 0, 0, 0, 0, 0, 0x70, 0, 0x80, 0, 0, 0, 0, 0, 0, 2},
+	}, ta.ids)
+}
+
+func TestAppendText(t *testing.T) {
+	log := Log{
+		Time:    time.Date(2009, 11, 10, 23, 0, 0, 0, time.UTC),
+		Message: "some msg",
+		Level:   WARNING,
+		Logger:  &Logger{Name: "my/logger"},
+		Data:    []interface{}{map[string]int{"size": 10}},
+	}
+	assert.Equal(t, "WARN my/logger [2009-11-10 23:00:00]: some msg map[size:10]",
+		string(AppendText(nil, log)))
+
+	// log without logger
+	assert.Equal(t, "DEBU  [0001-01-01 00:00:00]: some msg",
+		string(AppendText(nil, Log{Message: "some msg", Level: DEBUG})))
+}
+
+func TestDisabledLevelAllocs(t *testing.T) {
+	logger := &Logger{Name: "allocs", Level: INFO}
+	logger.Enable(NewConsole(ioutil.Discard, Conf{}))
+
+	err := errors.New("some error")
+	allocs := testing.AllocsPerRun(100, func() {
+		logger.Debug("some msg", "key", 42, err)
+		logger.Debugf("some %s %d", "message", 42)
+		logger.DebugFn(func() string { return "some msg" })
+	})
+	assert.Equal(t, 0.0, allocs)
+}
+
+type discardAppender struct{}
+
+func (discardAppender) Append(log Log) {}
+
+func (discardAppender) Id() string {
+	return "github.com/ildus/golog/test/discard"
+}
+
+// logger used by benchmarks, it doesn't print anything
+func benchLogger(appender Appender) *Logger {
+	logger := &Logger{Name: "github.com/ildus/golog/bench", Level: INFO}
+	logger.Enable(appender)
+	return logger
+}
+
+func BenchmarkDisabled(b *testing.B) {
+	logger := benchLogger(discardAppender{})
+	b.ReportAllocs()
+	for i := 0; i < b.N; i++ {
+		logger.Debug("some msg", "key", 42)
 	}
+}
 
-	return name
+func BenchmarkDisabledf(b *testing.B) {
+	logger := benchLogger(discardAppender{})
+	b.ReportAllocs()
+	for i := 0; i < b.N; i++ {
+		logger.Debugf("some %s %d", "message", 42)
+	}
 }
 
-func TestNormalizeName(t *testing.T) {
-	// name is too long
-	l := GetLogger("s.o.m.e.r.e.a.l.l.y.l.o.n.g.n.a.m.e.t.e.s.t.n.a.m.e.")
-	l.Debug(l.Name)
-	assert.Equal(t, normalizeNameLenInTest("s.o.m.e.r.e.a.l.l.y."), l.Name)
+func BenchmarkEnabled(b *testing.B) {
+	logger := benchLogger(discardAppender{})
+	b.ReportAllocs()
+	for i := 0; i < b.N; i++ {
+		logger.Info("some msg")
+	}
+}
 
-	l = GetLogger("github.com/ildus/golog")
-	l.Debug(l.Name)
-	assert.Equal(t, normalizeNameLenInTest("git/ild/gol"), l.Name)
+func BenchmarkEnabledData(b *testing.B) {
+	logger := benchLogger(discardAppender{})
+	b.ReportAllocs()
+	for i := 0; i < b.N; i++ {
+		logger.Info("some msg", "key", 42)
+	}
+}
 
-	l = GetLogger("github.com.ildus.golog")
-	l.Debug(l.Name)
-	assert.Equal(t, normalizeNameLenInTest("git.com.ild.gol"), l.Name)
+func BenchmarkEnabledParallel(b *testing.B) {
+	logger := benchLogger(discardAppender{})
+	b.ReportAllocs()
+	b.RunParallel(func(pb *testing.PB) {
+		for pb.Next() {
+			logger.Info("some msg")
+		}
+	})
+}
+
+func BenchmarkConsoleText(b *testing.B) {
+	logger := benchLogger(NewConsole(ioutil.Discard, Conf{"color": "never"}))
+	b.ReportAllocs()
+	for i := 0; i < b.N; i++ {
+		logger.Info("some msg")
+	}
+}
+
+func BenchmarkConsoleColor(b *testing.B) {
+	logger := benchLogger(NewConsole(ioutil.Discard, Conf{"color": "always"}))
+	b.ReportAllocs()
+	for i := 0; i < b.N; i++ {
+		logger.Info("some msg")
+	}
+}
 
-	// name is too short
-	l = GetLogger("main")
-	l.Debug(l.Name)
-	assert.Equal(t, normalizeNameLenInTest("main"), l.Name)
+func BenchmarkConsoleLogback(b *testing.B) {
+	logger := benchLogger(NewConsole(ioutil.Discard, Conf{"color": "never", "name": "{20}"}))
+	b.ReportAllocs()
+	for i := 0; i < b.N; i++ {
+		logger.Info("some msg")
+	}
+}
 
-	// name is correct
-	rightName := ""
-	for i := 0; i < curnamelen; i++ {
-		rightName += "a"
+func BenchmarkConsoleData(b *testing.B) {
+	logger := benchLogger(NewConsole(ioutil.Discard, Conf{"color": "never"}))
+	b.ReportAllocs()
+	for i := 0; i < b.N; i++ {
+		logger.Info("some msg", "key", 42)
 	}
+}
 
-	l = GetLogger(rightName)
-	l.Debug(l.Name)
-	assert.Equal(t, rightName, l.Name)
+func BenchmarkConsoleJSON(b *testing.B) {
+	logger := benchLogger(NewConsole(ioutil.Discard, Conf{"format": "json"}))
+	b.ReportAllocs()
+	for i := 0; i < b.N; i++ {
+		logger.Info("some msg")
+	}
+}
+
+func BenchmarkConsoleParallel(b *testing.B) {
+	logger := benchLogger(NewConsole(ioutil.Discard, Conf{"color": "never"}))
+	b.ReportAllocs()
+	b.RunParallel(func(pb *testing.PB) {
+		for pb.Next() {
+			logger.Info("some msg")
+		}
+	})
 }
@@ -0,0 +1,91 @@
+package logtest
+
+import (
+	"encoding/binary"
+	"sync"
+	"time"
+)
+
+// Environment is deterministic golog.Environment for tests. It returns
+// fixed time and pid, IDs are sequential (or fixed if ID is set),
+// and exits are recorded instead of exiting process.
+//
+//	env := logtest.NewEnvironment()
+//	logger := &golog.Logger{Name: "golden", Level: golog.DEBUG, Environment: env}
+type Environment struct {
+	// time returned by Now
+	Time time.Time
+
+	// duration added to Time after every call of Now
+	Step time.Duration
+
+	// pid returned by Pid
+	ProcessID int
+
+	// if set, NewID always returns it
+	ID []byte
+
+	mu    sync.Mutex
+	ids   uint64
+	exits []int
+}
+
+// Function for creating environment with time 2009-11-10 23:00:00 UTC
+// and pid 1234.
+func NewEnvironment() *Environment {
+	return &Environment{
+		Time:      time.Date(2009, 11, 10, 23, 0, 0, 0, time.UTC),
+		ProcessID: 1234,
+	}
+}
+
+// Now returns Time and advances it by Step.
+func (e *Environment) Now() time.Time {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+
+	now := e.Time
+	e.Time = e.Time.Add(e.Step)
+	return now
+}
+
+// Pid returns ProcessID.
+func (e *Environment) Pid() int {
+	return e.ProcessID
+}
+
+// NewID returns ID if it is set. Otherwise it returns time-ordered UUID
+// which has milliseconds of Time and sequence number of ID instead of
+// random bits, e.g. first ID is 0124e053-3580-7000-8000-000000000001.
+func (e *Environment) NewID() ([]byte, error) {
+	if e.ID != nil {
+		return append([]byte(nil), e.ID...), nil
+	}
+
+	e.mu.Lock()
+	defer e.mu.Unlock()
+
+	e.ids++
+	ms := e.Time.UnixNano() / int64(time.Millisecond)
+
+	bytes := make([]byte, 16)
+	binary.BigEndian.PutUint64(bytes, uint64(ms)<<16|0x7000)
+	binary.BigEndian.PutUint64(bytes[8:], e.ids|0x8000000000000000)
+	return bytes, nil
+}
+
+// Exit records status code.
+func (e *Environment) Exit(code int) {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+
+	e.exits = append(e.exits, code)
+}
+
+// ExitCodes returns recorded status codes.
+func (e *Environment) ExitCodes() []int {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+
+	return append([]int(nil), e.exits...)
+}
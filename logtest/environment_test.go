@@ -0,0 +1,39 @@
+package logtest
+
+import (
+	"testing"
+	"time"
+
+	"github.com/ildus/golog"
+	"github.com/ildus/golog/id"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestEnvironment(t *testing.T) {
+	env := NewEnvironment()
+	env.Step = time.Second
+
+	logger := &golog.Logger{Name: "env", Level: golog.DEBUG, Environment: env}
+	rec := Attach(t, logger)
+
+	logger.Info("first")
+	logger.Fatal("second")
+
+	logs := rec.Logs()
+	if assert.Len(t, logs, 2) {
+		assert.Equal(t, time.Date(2009, 11, 10, 23, 0, 0, 0, time.UTC), logs[0].Time)
+		assert.Equal(t, time.Date(2009, 11, 10, 23, 0, 1, 0, time.UTC), logs[1].Time)
+		assert.Equal(t, 1234, logs[0].Pid)
+
+		first, _ := id.Encode(logs[0].ID)
+		second, _ := id.Encode(logs[1].ID)
+		assert.Equal(t, "0124e053-3580-7000-8000-000000000001", first)
+		assert.Equal(t, "0124e053-3968-7000-8000-000000000002", second)
+	}
+	assert.Equal(t, []int{1}, env.ExitCodes())
+
+	env.ID = []byte{1, 2}
+	logID, err := env.NewID()
+	assert.Nil(t, err)
+	assert.Equal(t, []byte{1, 2}, logID)
+}
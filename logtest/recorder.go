@@ -0,0 +1,168 @@
+// Package logtest helps to test code which logs with golog.
+//
+// Recorder captures logs, so test can check what was logged:
+//
+//	func TestCharge(t *testing.T) {
+//		rec := logtest.Attach(t, golog.GetLogger("payments"))
+//
+//		charge(10)
+//
+//		rec.AssertLogged(t, golog.WARNING, "card declined")
+//		if !rec.HasField("amount", 10) {
+//			t.Error("amount isn't logged")
+//		}
+//	}
+package logtest
+
+import (
+	"reflect"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/ildus/golog"
+)
+
+// Recorder is appender which keeps logs in memory.
+// It can be used from several goroutines.
+type Recorder struct {
+	mu   sync.Mutex
+	logs []golog.Log
+}
+
+// Function for creating empty recorder.
+func NewRecorder() *Recorder {
+	return &Recorder{}
+}
+
+// Attach creates recorder and enables it on logger,
+// recorder is disabled when test finishes.
+func Attach(t testing.TB, logger *golog.Logger) *Recorder {
+	rec := NewRecorder()
+	Enable(t, logger, rec)
+	return rec
+}
+
+// Recording log.
+func (r *Recorder) Append(log golog.Log) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	r.logs = append(r.logs, log)
+}
+
+// Id of recorder.
+func (r *Recorder) Id() string {
+	return "github.com/ildus/golog/logtest/recorder"
+}
+
+// Logs returns copy of recorded logs in order in which they are appended.
+func (r *Recorder) Logs() []golog.Log {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	return append([]golog.Log(nil), r.logs...)
+}
+
+// Filter returns recorded logs with given level.
+func (r *Recorder) Filter(level golog.LogLevel) []golog.Log {
+	var logs []golog.Log
+	for _, log := range r.Logs() {
+		if log.Level == level {
+			logs = append(logs, log)
+		}
+	}
+	return logs
+}
+
+// Messages returns messages of recorded logs.
+func (r *Recorder) Messages() []string {
+	var messages []string
+	for _, log := range r.Logs() {
+		messages = append(messages, log.Message)
+	}
+	return messages
+}
+
+// HasField reports whether any recorded log has map in its data
+// with given key and value. Values are compared with reflect.DeepEqual.
+func (r *Recorder) HasField(key string, value interface{}) bool {
+	for _, log := range r.Logs() {
+		for _, item := range log.Data {
+			if field, ok := mapField(item, key); ok && reflect.DeepEqual(field, value) {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// Reset removes all recorded logs.
+func (r *Recorder) Reset() {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	r.logs = nil
+}
+
+// AssertLogged checks that log with given level and message
+// containing substring is recorded, test fails if it isn't.
+func (r *Recorder) AssertLogged(t testing.TB, level golog.LogLevel, substring string) bool {
+	t.Helper()
+
+	if r.find(level, substring) {
+		return true
+	}
+	t.Errorf("Expected %s log containing %q, recorded logs:%s", level, substring, r.dump())
+	return false
+}
+
+// AssertNotLogged checks that there is no log with given level and
+// message containing substring, test fails if it is recorded.
+func (r *Recorder) AssertNotLogged(t testing.TB, level golog.LogLevel, substring string) bool {
+	t.Helper()
+
+	if !r.find(level, substring) {
+		return true
+	}
+	t.Errorf("Unexpected %s log containing %q, recorded logs:%s", level, substring, r.dump())
+	return false
+}
+
+func (r *Recorder) find(level golog.LogLevel, substring string) bool {
+	for _, log := range r.Filter(level) {
+		if strings.Contains(log.Message, substring) {
+			return true
+		}
+	}
+	return false
+}
+
+// recorded logs in stdout format, one per line
+func (r *Recorder) dump() string {
+	logs := r.Logs()
+	if len(logs) == 0 {
+		return " none"
+	}
+
+	var buf []byte
+	for _, log := range logs {
+		buf = append(buf, "\n\t"...)
+		buf = golog.AppendText(buf, log)
+	}
+	return string(buf)
+}
+
+// value of key in map with string keys
+func mapField(item interface{}, key string) (interface{}, bool) {
+	v := reflect.ValueOf(item)
+	if v.Kind() != reflect.Map || v.Type().Key().Kind() != reflect.String {
+		return nil, false
+	}
+
+	field := v.MapIndex(reflect.ValueOf(key).Convert(v.Type().Key()))
+	if !field.IsValid() {
+		return nil, false
+	}
+	return field.Interface(), true
+}
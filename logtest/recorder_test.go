@@ -0,0 +1,92 @@
+package logtest
+
+import (
+	"fmt"
+	"sync"
+	"testing"
+
+	"github.com/ildus/golog"
+	"github.com/stretchr/testify/assert"
+)
+
+// collects failures instead of failing test
+type fakeT struct {
+	testing.TB
+	errors []string
+}
+
+func (t *fakeT) Helper() {}
+
+func (t *fakeT) Errorf(format string, args ...interface{}) {
+	t.errors = append(t.errors, fmt.Sprintf(format, args...))
+}
+
+func TestRecorder(t *testing.T) {
+	logger := &golog.Logger{Name: "payments", Level: golog.DEBUG}
+	rec := Attach(t, logger)
+
+	logger.Info("charged", map[string]interface{}{"amount": 10})
+	logger.Warn("card declined", map[string]string{"card": "visa"}, "retry")
+	logger.Warn("limit reached")
+
+	assert.Equal(t, []string{"charged", "card declined", "limit reached"}, rec.Messages())
+	assert.Len(t, rec.Logs(), 3)
+	assert.Len(t, rec.Filter(golog.WARNING), 2)
+	assert.Empty(t, rec.Filter(golog.ERROR))
+
+	assert.True(t, rec.HasField("amount", 10))
+	assert.True(t, rec.HasField("card", "visa"))
+	assert.False(t, rec.HasField("amount", int64(10)))
+	assert.False(t, rec.HasField("user", nil))
+
+	assert.True(t, rec.AssertLogged(t, golog.WARNING, "declined"))
+	assert.True(t, rec.AssertNotLogged(t, golog.ERROR, "declined"))
+
+	ft := &fakeT{}
+	assert.False(t, rec.AssertLogged(ft, golog.ERROR, "declined"))
+	assert.False(t, rec.AssertNotLogged(ft, golog.INFO, "charged"))
+	if assert.Len(t, ft.errors, 2) {
+		assert.Contains(t, ft.errors[0], `Expected ERROR log containing "declined"`)
+		assert.Contains(t, ft.errors[0], "WARN payments")
+	}
+
+	rec.Reset()
+	assert.Empty(t, rec.Logs())
+	rec.AssertNotLogged(t, golog.INFO, "charged")
+}
+
+func TestAttach(t *testing.T) {
+	logger := &golog.Logger{Name: "attached", Level: golog.DEBUG}
+	other := NewRecorder()
+	logger.Enable(other)
+
+	var rec *Recorder
+	t.Run("attached", func(t *testing.T) {
+		rec = Attach(t, logger)
+		logger.Info("during test")
+	})
+	logger.Info("after test")
+
+	assert.Equal(t, []string{"during test"}, rec.Messages())
+	assert.Equal(t, []string{"during test", "after test"}, other.Messages())
+}
+
+func TestRecorderConcurrent(t *testing.T) {
+	logger := &golog.Logger{Name: "concurrent", Level: golog.DEBUG}
+	rec := Attach(t, logger)
+
+	var wg sync.WaitGroup
+	for i := 0; i < 4; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for j := 0; j < 100; j++ {
+				logger.Info("message")
+				rec.Messages()
+			}
+		}()
+	}
+	wg.Wait()
+
+	assert.Len(t, rec.Logs(), 400)
+}
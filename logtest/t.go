@@ -0,0 +1,80 @@
+package logtest
+
+import (
+	"path/filepath"
+	"strconv"
+	"sync"
+	"testing"
+
+	"github.com/ildus/golog"
+)
+
+// TAppender writes logs to output of test with t.Log (by default it's shown
+// only if test fails or runs with -v). Logs appended after test finished
+// are dropped.
+//
+// Appender is marked as helper, but methods of golog.Logger can't be, so
+// every log starts with file and line of code which called logger, e.g.
+// "payments_test.go:42: WARN payments [...]: card declined".
+//
+// Appender only sees logs, not tests which made them, so when appenders of
+// parallel tests are enabled on one logger, every test gets logs of all of
+// them. Use separate loggers to keep output of parallel tests apart.
+type TAppender struct {
+	t    testing.TB
+	mu   sync.Mutex
+	done bool
+	buf  []byte
+}
+
+// T creates appender which writes to output of test t.
+// To use it for one test, enable it with Enable:
+//
+//	logtest.Enable(t, golog.Default, logtest.T(t))
+func T(t testing.TB) *TAppender {
+	ta := &TAppender{t: t}
+	t.Cleanup(func() {
+		ta.mu.Lock()
+		defer ta.mu.Unlock()
+
+		ta.done = true
+	})
+	return ta
+}
+
+// Enable enables appender on logger until test finishes.
+func Enable(t testing.TB, logger *golog.Logger, appender golog.Appender) {
+	logger.Enable(appender)
+	t.Cleanup(func() {
+		logger.Disable(appender)
+	})
+}
+
+// Writing log in stdout format to test output.
+func (ta *TAppender) Append(log golog.Log) {
+	ta.t.Helper()
+	file, line, _, ok := golog.Caller()
+
+	ta.mu.Lock()
+	defer ta.mu.Unlock()
+
+	// testing panics if test which finished logs
+	if ta.done {
+		return
+	}
+
+	ta.buf = ta.buf[:0]
+	if ok {
+		ta.buf = append(ta.buf, filepath.Base(file)...)
+		ta.buf = append(ta.buf, ':')
+		ta.buf = strconv.AppendInt(ta.buf, int64(line), 10)
+		ta.buf = append(ta.buf, ": "...)
+	}
+	ta.buf = golog.AppendText(ta.buf, log)
+	ta.t.Log(string(ta.buf))
+}
+
+// Id of T appender.
+func (ta *TAppender) Id() string {
+	return "github.com/ildus/golog/logtest/t"
+}
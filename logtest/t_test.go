@@ -0,0 +1,101 @@
+package logtest
+
+import (
+	"fmt"
+	"runtime"
+	"strconv"
+	"sync"
+	"testing"
+
+	"github.com/ildus/golog"
+	"github.com/stretchr/testify/assert"
+)
+
+// collects output and cleanups of test
+type outputT struct {
+	testing.TB
+	mu       sync.Mutex
+	lines    []string
+	cleanups []func()
+}
+
+func (t *outputT) Log(args ...interface{}) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	t.lines = append(t.lines, fmt.Sprint(args...))
+}
+
+func (t *outputT) Helper() {}
+
+func (t *outputT) Cleanup(f func()) {
+	t.cleanups = append(t.cleanups, f)
+}
+
+func (t *outputT) finish() {
+	for i := len(t.cleanups) - 1; i >= 0; i-- {
+		t.cleanups[i]()
+	}
+}
+
+func TestT(t *testing.T) {
+	logger := &golog.Logger{Name: "payments", Level: golog.DEBUG}
+	ot := &outputT{}
+	Enable(ot, logger, T(ot))
+
+	logger.Warn("card declined", "retry")
+	_, _, line, _ := runtime.Caller(0)
+	if assert.Len(t, ot.lines, 1) {
+		// log is attributed to the line which called logger
+		prefix := "t_test.go:" + strconv.Itoa(line-1) + ": "
+		assert.Regexp(t, `^`+prefix+`WARN payments \[.+\]: card declined retry$`, ot.lines[0])
+	}
+
+	// appender is disabled, and it ignores logs after test finished
+	ot.finish()
+	logger.Warn("after test")
+	assert.Len(t, ot.lines, 1)
+
+	ta := T(ot)
+	ot.finish()
+	ta.Append(golog.Log{Message: "late"})
+	assert.Len(t, ot.lines, 1)
+}
+
+func TestTWithoutLogger(t *testing.T) {
+	ot := &outputT{}
+	T(ot).Append(golog.Log{Message: "direct", Level: golog.INFO})
+
+	if assert.Len(t, ot.lines, 1) {
+		assert.Regexp(t, `^INFO  \[.+\]: direct$`, ot.lines[0])
+	}
+}
+
+func TestTParallel(t *testing.T) {
+	logger := &golog.Logger{Name: "parallel", Level: golog.DEBUG}
+	recs := make([]*Recorder, 4)
+
+	// group ends when all parallel subtests finish
+	t.Run("group", func(t *testing.T) {
+		for i := range recs {
+			i := i
+			t.Run(fmt.Sprint(i), func(t *testing.T) {
+				t.Parallel()
+
+				recs[i] = Attach(t, logger)
+				Enable(t, logger, T(t))
+
+				for j := 0; j < 50; j++ {
+					logger.Debug(fmt.Sprintf("subtest %d", i))
+				}
+				recs[i].AssertLogged(t, golog.DEBUG, fmt.Sprintf("subtest %d", i))
+			})
+		}
+	})
+
+	// every subtest removed its own appenders
+	logger.Debug("after subtests")
+	for _, rec := range recs {
+		rec.AssertNotLogged(t, golog.DEBUG, "after subtests")
+	}
+}
@@ -0,0 +1,204 @@
+package golog
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+// NameLayout decides how logger names are shown by console appenders.
+// Logger.Name itself is never changed.
+type NameLayout interface {
+	// appends displayed name to buf and returns extended buffer
+	AppendName(buf []byte, name string) []byte
+}
+
+// FullName shows names as they are, without alignment.
+var FullName NameLayout = fullName{}
+
+type fullName struct{}
+
+func (fullName) AppendName(buf []byte, name string) []byte {
+	return append(buf, name...)
+}
+
+// supported name separators, first one which splits name is used
+var separators = []byte{'/', '.', '-'}
+
+func splitName(name string) ([]string, string) {
+	for _, sep := range separators {
+		if parts := strings.Split(name, string(sep)); len(parts) > 1 {
+			return parts, string(sep)
+		}
+	}
+	return []string{name}, ""
+}
+
+// SegmentLayout cuts every segment of names longer than MaxLen to three
+// characters (github.com/someuser/somelib is shown as git/som/som), and
+// aligns names with spaces to the longest name shown so far. Alignment
+// width only grows, and every layout keeps its own width, so appenders
+// with separate layouts align names separately. It is the default
+// layout of console appenders.
+type SegmentLayout struct {
+	MaxLen int
+
+	mu    sync.Mutex
+	width int
+
+	// abbreviated names and MaxLen they were made for,
+	// there are few loggers, so names are kept forever
+	names    map[string]string
+	namesLen int
+}
+
+// Function for creating segment layout, names are at most maxLen long.
+func NewSegmentLayout(maxLen int) *SegmentLayout {
+	return &SegmentLayout{MaxLen: maxLen}
+}
+
+// minimum width of aligned names
+const minNameWidth = 7
+
+func (sl *SegmentLayout) AppendName(buf []byte, name string) []byte {
+	sl.mu.Lock()
+	if sl.names == nil || sl.namesLen != sl.MaxLen {
+		sl.names = map[string]string{}
+		sl.namesLen = sl.MaxLen
+	}
+	short, ok := sl.names[name]
+	if !ok {
+		short = abbreviateSegments(name, sl.MaxLen)
+		sl.names[name] = short
+	}
+	name = short
+
+	if sl.width < minNameWidth {
+		sl.width = minNameWidth
+	}
+	if len(name) > sl.width {
+		sl.width = len(name)
+	}
+	width := sl.width
+	sl.mu.Unlock()
+
+	return appendPadded(buf, name, width)
+}
+
+func abbreviateSegments(name string, maxLen int) string {
+	if len(name) <= maxLen {
+		return name
+	}
+
+	parts, separator := splitName(name)
+	if len(parts) == 1 {
+		return name[:maxLen]
+	}
+
+	var normalized string
+	appendSeparator := true
+
+	for i, part := range parts {
+		if len(part) == 0 {
+			appendSeparator = false
+		} else if len(part) > 3 {
+			part = part[:3]
+		}
+		normalized += part
+
+		if appendSeparator && i != len(parts)-1 {
+			normalized += separator
+		}
+	}
+
+	// if still too long
+	if len(normalized) > maxLen {
+		normalized = normalized[:maxLen]
+	}
+	return normalized
+}
+
+// LogbackLayout shortens names like %logger{Width} of logback: segments
+// are cut to their first character one by one from the left, and cutting
+// stops as soon as name fits into Width. The last segment is never cut,
+// so name can stay longer than Width. E.g. github.com/someuser/somelib is
+// shown as g/someuser/somelib with Width 20, and as g/s/somelib with
+// Width 10. Shorter names are padded with spaces to Width.
+type LogbackLayout struct {
+	Width int
+}
+
+func (ll LogbackLayout) AppendName(buf []byte, name string) []byte {
+	start := len(buf)
+	buf = appendLogback(buf, name, ll.Width)
+	for i := len(buf) - start; i < ll.Width; i++ {
+		buf = append(buf, ' ')
+	}
+	return buf
+}
+
+// appends name shortened to width, it is called for every log,
+// so segments are cut in place instead of splitting name
+func appendLogback(buf []byte, name string, width int) []byte {
+	if len(name) <= width {
+		return append(buf, name...)
+	}
+
+	// the first separator which splits name is used
+	var sep byte
+	for _, s := range separators {
+		if strings.IndexByte(name, s) >= 0 {
+			sep = s
+			break
+		}
+	}
+	if sep == 0 {
+		return append(buf, name...)
+	}
+
+	length := len(name)
+	for {
+		end := strings.IndexByte(name, sep)
+		if end < 0 {
+			// the last segment is never cut
+			return append(buf, name...)
+		}
+
+		segment := name[:end]
+		if length > width && len(segment) > 1 {
+			length -= len(segment) - 1
+			segment = segment[:1]
+		}
+		buf = append(buf, segment...)
+		buf = append(buf, sep)
+		name = name[end+1:]
+	}
+}
+
+func appendPadded(buf []byte, name string, width int) []byte {
+	buf = append(buf, name...)
+	for i := len(name); i < width; i++ {
+		buf = append(buf, ' ')
+	}
+	return buf
+}
+
+// parseNameLayout parses value of "name" configuration key:
+// "segments" (default), "{20}" for logback layout, or "full"
+func parseNameLayout(value string) (NameLayout, error) {
+	switch value {
+	case "", "segments":
+		return NewSegmentLayout(20), nil
+	case "full":
+		return FullName, nil
+	}
+
+	if strings.HasPrefix(value, "{") && strings.HasSuffix(value, "}") {
+		width, err := strconv.Atoi(value[1 : len(value)-1])
+		if err == nil && width > 0 {
+			return LogbackLayout{Width: width}, nil
+		}
+	}
+	return nil, fmt.Errorf("Unsupported name %q, use segments, full or {width}", value)
+}
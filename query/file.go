@@ -0,0 +1,244 @@
+package query
+
+import (
+	"bufio"
+	"compress/gzip"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/ildus/golog"
+)
+
+// FileReader reads JSON lines written by FileAppender. Besides the file
+// itself, rotated files named like "<path>.1", "<path>.2.gz" or
+// "<path>-20240101" are read, gzipped files are decompressed.
+// Files are read from the oldest to the newest by their names: files with
+// date or other suffix are sorted by name, then numbered files are read
+// from the highest number, and the log file itself is read last.
+//
+// Lines which can't be decoded are skipped, Err of iterator returns
+// MalformedError describing them.
+type FileReader struct {
+	Path string
+}
+
+// MalformedError reports lines which were skipped
+// because they couldn't be decoded.
+type MalformedError struct {
+	// number of skipped lines
+	Lines int
+
+	// error of the first skipped line
+	First error
+}
+
+func (e *MalformedError) Error() string {
+	if e.Lines == 1 {
+		return e.First.Error()
+	}
+	return fmt.Sprintf("%d malformed lines skipped, first: %s", e.Lines, e.First)
+}
+
+// Function for creating file reader,
+// it accepts the same "path" key as FileAppender.
+func File(cnf golog.Conf) (*FileReader, error) {
+	if cnf["path"] == "" {
+		return nil, fmt.Errorf("Missing path")
+	}
+	return &FileReader{Path: cnf["path"]}, nil
+}
+
+// Read returns logs matching filter. Logs are streamed in the order
+// they are found in files, so for files written by one appender
+// they are ordered by time.
+func (fr *FileReader) Read(filter Filter) (Iterator, error) {
+	files, err := fr.files()
+	if err != nil {
+		return nil, err
+	}
+	return &fileIterator{files: files, filter: filter}, nil
+}
+
+// files returns paths of log file and its rotated files, oldest first
+func (fr *FileReader) files() ([]string, error) {
+	var files []rotatedFile
+	for _, pattern := range []string{fr.Path, fr.Path + ".*", fr.Path + "-*"} {
+		paths, err := filepath.Glob(pattern)
+		if err != nil {
+			return nil, err
+		}
+
+		for _, path := range paths {
+			info, err := os.Stat(path)
+			if err != nil || info.IsDir() {
+				continue
+			}
+			files = append(files, newRotatedFile(fr.Path, path))
+		}
+	}
+
+	if len(files) == 0 {
+		return nil, fmt.Errorf("No log files found at %q", fr.Path)
+	}
+
+	sort.Slice(files, func(i, j int) bool {
+		return files[i].older(files[j])
+	})
+
+	paths := make([]string, len(files))
+	for i, f := range files {
+		paths[i] = f.path
+	}
+	return paths, nil
+}
+
+// rotatedFile is log file or one of its rotated files
+type rotatedFile struct {
+	path string
+
+	// 0 for files with date or other suffix, 1 for
+	// numbered files and 2 for log file itself
+	group int
+
+	// number of numbered file, higher is older
+	index int
+}
+
+func newRotatedFile(base, path string) rotatedFile {
+	if path == base {
+		return rotatedFile{path: path, group: 2}
+	}
+
+	suffix := strings.TrimSuffix(strings.TrimPrefix(path, base), ".gz")
+	if strings.HasPrefix(suffix, ".") {
+		if index, err := strconv.Atoi(suffix[1:]); err == nil && index >= 0 {
+			return rotatedFile{path: path, group: 1, index: index}
+		}
+	}
+	return rotatedFile{path: path}
+}
+
+func (f rotatedFile) older(other rotatedFile) bool {
+	if f.group != other.group {
+		return f.group < other.group
+	}
+	if f.group == 1 && f.index != other.index {
+		return f.index > other.index
+	}
+	return f.path < other.path
+}
+
+type fileIterator struct {
+	files  []string
+	filter Filter
+
+	file   *os.File
+	reader *bufio.Reader
+	path   string
+	line   int
+
+	log       golog.Log
+	err       error
+	malformed *MalformedError
+}
+
+func (it *fileIterator) Next() bool {
+	for it.err == nil {
+		if it.reader == nil {
+			if len(it.files) == 0 {
+				return false
+			}
+			it.err = it.open(it.files[0])
+			it.files = it.files[1:]
+			continue
+		}
+
+		line, err := it.reader.ReadBytes('\n')
+		if len(line) > 0 && !isBlank(line) {
+			it.line++
+			log := golog.Log{}
+			if err := json.Unmarshal(line, &log); err != nil {
+				it.skip(fmt.Errorf("Error reading log at %s:%d: %s", it.path, it.line, err))
+			} else if it.filter.Match(log) {
+				it.log = log
+				return true
+			}
+		}
+
+		if err == io.EOF {
+			it.closeFile()
+		} else if err != nil {
+			it.err = err
+		}
+	}
+	return false
+}
+
+func (it *fileIterator) skip(err error) {
+	if it.malformed == nil {
+		it.malformed = &MalformedError{First: err}
+	}
+	it.malformed.Lines++
+}
+
+func (it *fileIterator) open(path string) error {
+	f, err := os.Open(path)
+	if err != nil {
+		return err
+	}
+
+	it.file, it.path, it.line = f, path, 0
+	it.reader = bufio.NewReader(f)
+
+	if filepath.Ext(path) == ".gz" {
+		gz, err := gzip.NewReader(it.reader)
+		if err != nil {
+			it.closeFile()
+			return fmt.Errorf("Error reading %s: %s", path, err)
+		}
+		it.reader = bufio.NewReader(gz)
+	}
+	return nil
+}
+
+func (it *fileIterator) closeFile() {
+	if it.file != nil {
+		it.file.Close()
+	}
+	it.file, it.reader = nil, nil
+}
+
+func (it *fileIterator) Log() golog.Log {
+	return it.log
+}
+
+func (it *fileIterator) Err() error {
+	if it.err != nil {
+		return it.err
+	}
+	if it.malformed != nil {
+		return it.malformed
+	}
+	return nil
+}
+
+func (it *fileIterator) Close() error {
+	it.closeFile()
+	it.files = nil
+	return nil
+}
+
+func isBlank(line []byte) bool {
+	for _, b := range line {
+		if b != ' ' && b != '\t' && b != '\r' && b != '\n' {
+			return false
+		}
+	}
+	return true
+}
@@ -0,0 +1,150 @@
+package query
+
+import (
+	"compress/gzip"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/ildus/golog"
+	"github.com/ildus/golog/appenders"
+	"github.com/stretchr/testify/assert"
+)
+
+func readAll(t *testing.T, reader Reader, filter Filter) []string {
+	it, err := reader.Read(filter)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer it.Close()
+
+	var messages []string
+	for it.Next() {
+		messages = append(messages, it.Log().Message)
+	}
+	assert.Nil(t, it.Err())
+	return messages
+}
+
+// writes logs with FileAppender, one log per second starting from start
+func writeLogs(path string, start time.Time, messages ...string) {
+	appender := appenders.File(golog.Conf{"path": path})
+	logger := &golog.Logger{Name: "payments"}
+
+	for i, message := range messages {
+		appender.Append(golog.Log{
+			Time:    start.Add(time.Duration(i) * time.Second),
+			Message: message,
+			Level:   golog.LogLevel(i % 8),
+			Logger:  logger,
+		})
+	}
+}
+
+func gzipFile(t *testing.T, path string) {
+	content, err := ioutil.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	f, err := os.Create(path + ".gz")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+
+	w := gzip.NewWriter(f)
+	w.Write(content)
+	w.Close()
+	os.Remove(path)
+}
+
+func TestRotatedFiles(t *testing.T) {
+	dir, err := ioutil.TempDir("", "golog-query")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	path := filepath.Join(dir, "app.log")
+	for _, name := range []string{"app.log", "app.log.1", "app.log.2.gz", "app.log.10",
+		"app.log-20240102", "app.log-20240101.gz"} {
+		ioutil.WriteFile(filepath.Join(dir, name), nil, 0666)
+	}
+
+	files, err := (&FileReader{Path: path}).files()
+	assert.Nil(t, err)
+	for i := range files {
+		files[i] = filepath.Base(files[i])
+	}
+	assert.Equal(t, []string{"app.log-20240101.gz", "app.log-20240102",
+		"app.log.10", "app.log.2.gz", "app.log.1", "app.log"}, files)
+}
+
+func TestFileReader(t *testing.T) {
+	dir, err := ioutil.TempDir("", "golog-query")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	path := filepath.Join(dir, "app.log")
+	start := time.Date(2009, 11, 10, 23, 0, 0, 0, time.UTC)
+
+	// rotated files are ordered by names, not by modification
+	// times, which can be changed e.g. by copying
+	writeLogs(path+".2", start, "first", "second")
+	gzipFile(t, path+".2")
+	os.Chtimes(path+".2.gz", start.Add(time.Minute), start.Add(time.Minute))
+
+	writeLogs(path+".1", start.Add(time.Minute), "third")
+	os.Chtimes(path+".1", start, start)
+
+	writeLogs(path, start.Add(time.Hour), "fourth", "fifth")
+	// file in the same directory which is not rotated log
+	writeLogs(filepath.Join(dir, "other.log"), start, "other")
+
+	reader, err := File(golog.Conf{"path": path})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	assert.Equal(t, []string{"first", "second", "third", "fourth", "fifth"}, readAll(t, reader, Filter{}))
+	assert.Equal(t, []string{"second", "third"}, readAll(t, reader, Filter{
+		From: start.Add(time.Second),
+		To:   start.Add(time.Hour),
+	}))
+	assert.Equal(t, []string{"first", "third", "fourth"}, readAll(t, reader, Filter{
+		Levels: &LevelRange{golog.EMERGENCY, golog.EMERGENCY},
+	}))
+	assert.Equal(t, []string{"fifth"}, readAll(t, reader, Filter{Message: "fif", Logger: "pay*"}))
+	assert.Empty(t, readAll(t, reader, Filter{Logger: "orders"}))
+
+	// broken lines are skipped and reported
+	f, _ := os.OpenFile(path+".1", os.O_APPEND|os.O_WRONLY, 0666)
+	f.WriteString("{broken\n")
+	f.WriteString("also broken\n")
+	f.Close()
+
+	it, err := reader.Read(Filter{})
+	assert.Nil(t, err)
+	var messages []string
+	for it.Next() {
+		messages = append(messages, it.Log().Message)
+	}
+	assert.Equal(t, []string{"first", "second", "third", "fourth", "fifth"}, messages)
+	if malformed, ok := it.Err().(*MalformedError); assert.True(t, ok) {
+		assert.Equal(t, 2, malformed.Lines)
+		assert.Contains(t, malformed.First.Error(), "app.log.1:2")
+	}
+	assert.Nil(t, it.Close())
+
+	_, err = File(golog.Conf{})
+	assert.NotNil(t, err)
+
+	reader, _ = File(golog.Conf{"path": filepath.Join(dir, "missing.log")})
+	_, err = reader.Read(Filter{})
+	assert.NotNil(t, err)
+}
@@ -0,0 +1,123 @@
+package query
+
+import (
+	"fmt"
+	"regexp"
+	"time"
+
+	"github.com/ildus/golog"
+	"gopkg.in/mgo.v2"
+	"gopkg.in/mgo.v2/bson"
+)
+
+const defaultMongoTimeout = 10 * time.Second
+
+// MongoReader reads logs inserted by MongoAppender. Filter is translated
+// into Mongo query, so indexes created by appender are used.
+type MongoReader struct {
+	session    *mgo.Session
+	db         string
+	collection string
+}
+
+// Function for creating Mongo reader. It accepts the same "host", "db",
+// "collection", "username", "password" and "dial_timeout" keys as MongoAppender.
+func Mongo(cnf golog.Conf) (*MongoReader, error) {
+	timeout := defaultMongoTimeout
+	if val := cnf["dial_timeout"]; val != "" {
+		var err error
+		if timeout, err = time.ParseDuration(val); err != nil {
+			return nil, fmt.Errorf("Invalid dial_timeout %q: %s", val, err)
+		}
+	}
+
+	session, err := mgo.DialWithInfo(&mgo.DialInfo{
+		Database: cnf["db"],
+		Username: cnf["username"],
+		Password: cnf["password"],
+		Addrs:    []string{cnf["host"]},
+		Timeout:  timeout,
+	})
+	if err != nil {
+		return nil, fmt.Errorf("Error connecting to Mongo %q: %s", cnf["host"], err)
+	}
+
+	return &MongoReader{
+		session:    session,
+		db:         cnf["db"],
+		collection: cnf["collection"],
+	}, nil
+}
+
+// Read returns logs matching filter ordered by time.
+func (mr *MongoReader) Read(filter Filter) (Iterator, error) {
+	session := mr.session.Copy()
+	iter := session.DB(mr.db).C(mr.collection).
+		Find(mongoQuery(filter)).
+		Sort("time", "_id").
+		Iter()
+
+	return &mongoIterator{session: session, iter: iter}, nil
+}
+
+// Close closes session.
+func (mr *MongoReader) Close() error {
+	mr.session.Close()
+	return nil
+}
+
+// mongoQuery translates filter into query on documents
+// described in golog.SchemaVersion
+func mongoQuery(filter Filter) bson.M {
+	query := bson.M{}
+
+	times := bson.M{}
+	if !filter.From.IsZero() {
+		times["$gte"] = filter.From
+	}
+	if !filter.To.IsZero() {
+		times["$lt"] = filter.To
+	}
+	if len(times) > 0 {
+		query["time"] = times
+	}
+
+	if filter.Levels != nil {
+		query["level"] = bson.M{"$gte": filter.Levels.From, "$lte": filter.Levels.To}
+	}
+
+	if filter.Logger != "" {
+		query["logger.name"] = bson.RegEx{Pattern: patternRegexp(filter.Logger), Options: "s"}
+	}
+
+	if filter.Message != "" {
+		query["message"] = bson.RegEx{Pattern: regexp.QuoteMeta(filter.Message), Options: "s"}
+	}
+
+	return query
+}
+
+type mongoIterator struct {
+	session *mgo.Session
+	iter    *mgo.Iter
+	log     golog.Log
+}
+
+func (it *mongoIterator) Next() bool {
+	it.log = golog.Log{}
+	return it.iter.Next(&it.log)
+}
+
+func (it *mongoIterator) Log() golog.Log {
+	return it.log
+}
+
+func (it *mongoIterator) Err() error {
+	return it.iter.Err()
+}
+
+func (it *mongoIterator) Close() error {
+	err := it.iter.Close()
+	it.session.Close()
+	return err
+}
@@ -0,0 +1,50 @@
+package query
+
+import (
+	"testing"
+	"time"
+
+	"github.com/ildus/golog"
+	"github.com/ildus/golog/appenders"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestMongoReader(t *testing.T) {
+	cnf := golog.Conf{
+		"host":       "127.0.0.1:27017",
+		"db":         "test",
+		"collection": "query_logs",
+	}
+
+	reader, err := Mongo(cnf)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer reader.Close()
+	reader.session.DB("test").C("query_logs").DropCollection()
+
+	appender, err := appenders.Mongo(cnf)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	start := time.Date(2009, 11, 10, 23, 0, 0, 0, time.UTC)
+	logger := &golog.Logger{Name: "payments"}
+	for i, message := range []string{"first", "second", "third"} {
+		appender.Append(golog.Log{
+			Time:    start.Add(time.Duration(i) * time.Second),
+			Message: message,
+			Level:   golog.LogLevel(i),
+			Logger:  logger,
+		})
+	}
+	assert.Nil(t, appender.Close())
+
+	assert.Equal(t, []string{"first", "second", "third"}, readAll(t, reader, Filter{}))
+	assert.Equal(t, []string{"second"}, readAll(t, reader, Filter{
+		From:   start.Add(time.Second),
+		Levels: &LevelRange{golog.EMERGENCY, golog.ALERT},
+	}))
+	assert.Equal(t, []string{"third"}, readAll(t, reader, Filter{Logger: "pay*", Message: "hir"}))
+	assert.Empty(t, readAll(t, reader, Filter{Logger: "orders"}))
+}
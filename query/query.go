@@ -0,0 +1,137 @@
+// Package query reads logs back from stores written by appenders.
+//
+//	reader, err := query.File(golog.Conf{"path": "/var/log/app.log"})
+//	...
+//	it, err := reader.Read(query.Filter{
+//		From:   time.Now().Add(-time.Hour),
+//		Levels: &query.LevelRange{From: golog.EMERGENCY, To: golog.WARNING},
+//		Logger: "payments.*",
+//	})
+//	...
+//	defer it.Close()
+//	for it.Next() {
+//		log := it.Log()
+//		...
+//	}
+//	if err := it.Err(); err != nil {
+//		...
+//	}
+package query
+
+import (
+	"regexp"
+	"strings"
+	"time"
+
+	"github.com/ildus/golog"
+)
+
+// Reader returns logs matching filter, ordered by time.
+type Reader interface {
+	Read(filter Filter) (Iterator, error)
+}
+
+// Iterator streams logs returned by Reader.
+type Iterator interface {
+	// Next moves to next log, it returns false
+	// when there are no more logs or error occurs.
+	Next() bool
+
+	// Log returns current log.
+	Log() golog.Log
+
+	// Err returns error which stopped iteration.
+	Err() error
+
+	// Close releases resources used by iterator.
+	Close() error
+}
+
+// Filter selects logs. Zero value of every field matches all logs.
+type Filter struct {
+	// logs made at From or later and before To
+	From time.Time
+	To   time.Time
+
+	// levels of logs
+	Levels *LevelRange
+
+	// pattern of logger name, "*" matches any sequence
+	// of characters and "?" matches one character
+	Logger string
+
+	// substring of log message
+	Message string
+}
+
+// LevelRange matches levels between From and To inclusive, levels are
+// compared by number, so LevelRange{golog.EMERGENCY, golog.WARNING}
+// matches warnings and more severe logs.
+type LevelRange struct {
+	From golog.LogLevel
+	To   golog.LogLevel
+}
+
+// Match reports if log matches filter.
+func (f Filter) Match(log golog.Log) bool {
+	if !f.From.IsZero() && log.Time.Before(f.From) {
+		return false
+	}
+	if !f.To.IsZero() && !log.Time.Before(f.To) {
+		return false
+	}
+
+	if f.Levels != nil && (log.Level < f.Levels.From || log.Level > f.Levels.To) {
+		return false
+	}
+
+	if f.Logger != "" && (log.Logger == nil || !matchPattern(f.Logger, log.Logger.Name)) {
+		return false
+	}
+
+	return strings.Contains(log.Message, f.Message)
+}
+
+func matchPattern(pattern, name string) bool {
+	p, n := []rune(pattern), []rune(name)
+
+	// position after last "*" and position in name where it started to match
+	star, mark := -1, 0
+
+	for i, j := 0, 0; j < len(n) || i < len(p); {
+		switch {
+		case i < len(p) && p[i] == '*':
+			star, mark = i+1, j
+			i++
+		case i < len(p) && j < len(n) && (p[i] == '?' || p[i] == n[j]):
+			i++
+			j++
+		case star >= 0 && mark < len(n):
+			// "*" takes one more character
+			mark++
+			i, j = star, mark
+		default:
+			return false
+		}
+	}
+	return true
+}
+
+// patternRegexp converts logger name pattern to regular expression
+func patternRegexp(pattern string) string {
+	re := make([]byte, 0, len(pattern)+8)
+	re = append(re, '^')
+
+	for _, part := range strings.SplitAfter(pattern, "") {
+		switch part {
+		case "*":
+			re = append(re, ".*"...)
+		case "?":
+			re = append(re, '.')
+		default:
+			re = append(re, regexp.QuoteMeta(part)...)
+		}
+	}
+
+	return string(append(re, '$'))
+}
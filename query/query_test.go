@@ -0,0 +1,81 @@
+package query
+
+import (
+	"testing"
+	"time"
+
+	"github.com/ildus/golog"
+	"github.com/stretchr/testify/assert"
+	"gopkg.in/mgo.v2/bson"
+)
+
+func TestMatchPattern(t *testing.T) {
+	for _, c := range []struct {
+		pattern, name string
+		match         bool
+	}{
+		{"payments", "payments", true},
+		{"payments", "payments.api", false},
+		{"payments*", "payments.api", true},
+		{"*.api", "payments.api", true},
+		{"*.api", "payments.api.v2", false},
+		{"*api*", "payments.api.v2", true},
+		{"pay?ents", "payments", true},
+		{"pay?ents", "paments", false},
+		{"*", "", true},
+		{"?", "", false},
+		{"a*b*c", "abxbc", true},
+		{"a*b*c", "abxbd", false},
+		{"лог?", "логи", true},
+	} {
+		assert.Equal(t, c.match, matchPattern(c.pattern, c.name), "%q %q", c.pattern, c.name)
+	}
+}
+
+func TestFilterMatch(t *testing.T) {
+	now := time.Date(2009, 11, 10, 23, 0, 0, 0, time.UTC)
+	log := golog.Log{
+		Time:    now,
+		Message: "payment declined",
+		Level:   golog.WARNING,
+		Logger:  &golog.Logger{Name: "payments.api"},
+	}
+
+	assert.True(t, Filter{}.Match(log))
+	assert.True(t, Filter{From: now, To: now.Add(time.Second)}.Match(log))
+	assert.False(t, Filter{From: now.Add(time.Nanosecond)}.Match(log))
+	assert.False(t, Filter{To: now}.Match(log))
+
+	assert.True(t, Filter{Levels: &LevelRange{golog.EMERGENCY, golog.WARNING}}.Match(log))
+	assert.True(t, Filter{Levels: &LevelRange{golog.WARNING, golog.WARNING}}.Match(log))
+	assert.False(t, Filter{Levels: &LevelRange{golog.EMERGENCY, golog.ERROR}}.Match(log))
+	assert.False(t, Filter{Levels: &LevelRange{golog.NOTICE, golog.DEBUG}}.Match(log))
+
+	assert.True(t, Filter{Logger: "payments.*"}.Match(log))
+	assert.False(t, Filter{Logger: "orders.*"}.Match(log))
+	assert.False(t, Filter{Logger: "*"}.Match(golog.Log{}))
+
+	assert.True(t, Filter{Message: "declined"}.Match(log))
+	assert.False(t, Filter{Message: "accepted"}.Match(log))
+}
+
+func TestFilterQuery(t *testing.T) {
+	from := time.Date(2009, 11, 10, 23, 0, 0, 0, time.UTC)
+
+	assert.Equal(t, bson.M{}, mongoQuery(Filter{}))
+	assert.Equal(t, bson.M{
+		"time":        bson.M{"$gte": from},
+		"level":       bson.M{"$gte": golog.EMERGENCY, "$lte": golog.ERROR},
+		"logger.name": bson.RegEx{Pattern: `^payments\..*$`, Options: "s"},
+		"message":     bson.RegEx{Pattern: `1\+1`, Options: "s"},
+	}, mongoQuery(Filter{
+		From:    from,
+		Levels:  &LevelRange{golog.EMERGENCY, golog.ERROR},
+		Logger:  "payments.*",
+		Message: "1+1",
+	}))
+
+	to := from.Add(time.Hour)
+	assert.Equal(t, bson.M{"time": bson.M{"$gte": from, "$lt": to}},
+		mongoQuery(Filter{From: from, To: to}))
+}
@@ -0,0 +1,308 @@
+package golog
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"math"
+	"reflect"
+	"strings"
+	"time"
+
+	"github.com/ildus/golog/id"
+	"gopkg.in/mgo.v2/bson"
+)
+
+// Version of document schema used for Log in JSON and BSON.
+//
+// Log is stored as document with keys:
+//
+//	v          - schema version
+//	id         - unique ID of log, hyphenated UUID string in JSON,
+//	             binary UUID stored as _id in BSON, omitted if log has no ID
+//	time       - time of log, RFC3339Nano string in JSON, date in BSON
+//	             (BSON dates have millisecond precision)
+//	level      - level number (syslog severity)
+//	level_name - level name, e.g. "INFO"
+//	message    - logged message
+//	pid        - id of process which made log
+//	logger     - object with "name" of logger, omitted if log has no logger
+//	data       - normalized log data, omitted if log has no data
+//
+// Data is normalized to values which look the same after they are read back:
+// maps become objects with string keys, structs become objects with exported
+// fields (json tags are honoured), errors become their messages, times
+// become RFC3339Nano strings, integers become int64 and other numbers float64
+// (numbers read from JSON become int64 if they have no fractional part).
+// Values nested deeper than maxDataDepth levels are replaced with null.
+//
+// Documents without "v" are written by previous versions, they are read too.
+const SchemaVersion = 1
+
+// limit of nested values in data, it also stops cyclic values
+const maxDataDepth = 8
+
+type logDocument struct {
+	ID        logID           `json:"id,omitempty" bson:"_id,omitempty"`
+	V         int             `json:"v" bson:"v"`
+	Time      time.Time       `json:"time" bson:"time"`
+	Level     LogLevel        `json:"level" bson:"level"`
+	LevelName string          `json:"level_name,omitempty" bson:"level_name,omitempty"`
+	Message   string          `json:"message" bson:"message"`
+	Pid       int             `json:"pid" bson:"pid"`
+	Logger    *loggerDocument `json:"logger,omitempty" bson:"logger,omitempty"`
+	Data      []interface{}   `json:"data,omitempty" bson:"data,omitempty"`
+}
+
+type loggerDocument struct {
+	Name string `json:"name" bson:"name"`
+}
+
+// logID is encoded as hyphenated string in JSON and as binary UUID in BSON
+type logID []byte
+
+func (lid logID) MarshalJSON() ([]byte, error) {
+	s, err := id.Encode(lid)
+	if err != nil {
+		return nil, err
+	}
+	return json.Marshal(s)
+}
+
+func (lid *logID) UnmarshalJSON(b []byte) error {
+	var s string
+	if err := json.Unmarshal(b, &s); err != nil {
+		return err
+	}
+
+	bytes, err := id.DecodeString(s)
+	if err != nil {
+		return err
+	}
+	*lid = bytes
+	return nil
+}
+
+func (lid logID) GetBSON() (interface{}, error) {
+	return bson.Binary{Kind: 0x04, Data: lid}, nil
+}
+
+// documents stored before logs had IDs have ObjectId
+// assigned by Mongo, such IDs are ignored
+func (lid *logID) SetBSON(raw bson.Raw) error {
+	if raw.Kind != 0x05 {
+		return nil
+	}
+
+	binary := bson.Binary{}
+	if err := raw.Unmarshal(&binary); err != nil {
+		return err
+	}
+	if len(binary.Data) == 16 {
+		*lid = binary.Data
+	}
+	return nil
+}
+
+func (log Log) document() *logDocument {
+	doc := &logDocument{
+		V:         SchemaVersion,
+		Time:      log.Time,
+		Level:     log.Level,
+		LevelName: log.Level.String(),
+		Message:   log.Message,
+		Pid:       log.Pid,
+		Data:      normalizeData(log.Data),
+	}
+
+	// IDs which aren't UUIDs are dropped
+	if len(log.ID) == 16 {
+		doc.ID = log.ID
+	}
+	if log.Logger != nil {
+		doc.Logger = &loggerDocument{Name: log.Logger.Name}
+	}
+	return doc
+}
+
+// log read from document gets logger which has only name
+func (log *Log) setDocument(doc *logDocument) error {
+	if doc.V > SchemaVersion {
+		return fmt.Errorf("Unsupported log schema version %d", doc.V)
+	}
+
+	*log = Log{
+		ID:      doc.ID,
+		Time:    doc.Time,
+		Level:   doc.Level,
+		Message: doc.Message,
+		Pid:     doc.Pid,
+		Data:    normalizeData(doc.Data),
+	}
+
+	if doc.Logger != nil {
+		log.Logger = &Logger{Name: doc.Logger.Name}
+	}
+	return nil
+}
+
+// Encodes log as JSON document described in SchemaVersion.
+func (log Log) MarshalJSON() ([]byte, error) {
+	return json.Marshal(log.document())
+}
+
+// Decodes log from JSON document described in SchemaVersion.
+func (log *Log) UnmarshalJSON(b []byte) error {
+	decoder := json.NewDecoder(bytes.NewReader(b))
+	// integers are kept precise
+	decoder.UseNumber()
+
+	doc := &logDocument{}
+	if err := decoder.Decode(doc); err != nil {
+		return err
+	}
+	return log.setDocument(doc)
+}
+
+// Encodes log as BSON document described in SchemaVersion.
+func (log Log) GetBSON() (interface{}, error) {
+	return log.document(), nil
+}
+
+// Decodes log from BSON document described in SchemaVersion.
+func (log *Log) SetBSON(raw bson.Raw) error {
+	doc := &logDocument{}
+	if err := raw.Unmarshal(doc); err != nil {
+		return err
+	}
+	return log.setDocument(doc)
+}
+
+func normalizeData(data []interface{}) []interface{} {
+	if data == nil {
+		return nil
+	}
+
+	normalized := make([]interface{}, len(data))
+	for i, item := range data {
+		normalized[i] = normalize(reflect.ValueOf(item), 1)
+	}
+	return normalized
+}
+
+var errorType = reflect.TypeOf((*error)(nil)).Elem()
+
+func normalize(v reflect.Value, depth int) interface{} {
+	if !v.IsValid() || depth > maxDataDepth {
+		return nil
+	}
+
+	if v.Kind() == reflect.Interface {
+		if v.IsNil() {
+			return nil
+		}
+		v = v.Elem()
+	}
+
+	switch val := v.Interface().(type) {
+	case json.Number:
+		if n, err := val.Int64(); err == nil {
+			return n
+		}
+		f, _ := val.Float64()
+		return f
+	case time.Time:
+		return val.Format(time.RFC3339Nano)
+	case []byte:
+		return string(val)
+	}
+
+	if v.Type().Implements(errorType) {
+		if v.Kind() == reflect.Ptr && v.IsNil() {
+			return nil
+		}
+		return v.Interface().(error).Error()
+	}
+
+	switch v.Kind() {
+	case reflect.Ptr:
+		if v.IsNil() {
+			return nil
+		}
+		// pointers to pointers or interfaces are counted as nesting,
+		// otherwise they could make cycle which never gets deeper
+		elem := v.Elem()
+		if elem.Kind() == reflect.Ptr || elem.Kind() == reflect.Interface {
+			return normalize(elem, depth+1)
+		}
+		return normalize(elem, depth)
+	case reflect.Bool:
+		return v.Bool()
+	case reflect.String:
+		return v.String()
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		return v.Int()
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64, reflect.Uintptr:
+		if n := v.Uint(); n <= math.MaxInt64 {
+			return int64(n)
+		}
+		return float64(v.Uint())
+	case reflect.Float32, reflect.Float64:
+		f := v.Float()
+		// not representable in JSON
+		if math.IsNaN(f) || math.IsInf(f, 0) {
+			return fmt.Sprint(f)
+		}
+		return f
+	case reflect.Complex64, reflect.Complex128:
+		return fmt.Sprint(v.Interface())
+	case reflect.Slice, reflect.Array:
+		if v.Kind() == reflect.Slice && v.IsNil() {
+			return nil
+		}
+		items := make([]interface{}, v.Len())
+		for i := range items {
+			items[i] = normalize(v.Index(i), depth+1)
+		}
+		return items
+	case reflect.Map:
+		if v.IsNil() {
+			return nil
+		}
+		obj := make(map[string]interface{}, v.Len())
+		for _, key := range v.MapKeys() {
+			obj[fmt.Sprint(key.Interface())] = normalize(v.MapIndex(key), depth+1)
+		}
+		return obj
+	case reflect.Struct:
+		return normalizeStruct(v, depth)
+	}
+
+	// channels, functions and unsafe pointers
+	return nil
+}
+
+func normalizeStruct(v reflect.Value, depth int) map[string]interface{} {
+	obj := map[string]interface{}{}
+	t := v.Type()
+
+	for i := 0; i < t.NumField(); i++ {
+		field := t.Field(i)
+		if field.PkgPath != "" {
+			continue
+		}
+
+		name := field.Name
+		if tag := field.Tag.Get("json"); tag != "" {
+			if tag == "-" {
+				continue
+			}
+			if tagName := strings.Split(tag, ",")[0]; tagName != "" {
+				name = tagName
+			}
+		}
+
+		obj[name] = normalize(v.Field(i), depth+1)
+	}
+	return obj
+}
@@ -0,0 +1,163 @@
+package golog
+
+import (
+	"encoding/json"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/ildus/golog/id"
+	"github.com/stretchr/testify/assert"
+	"gopkg.in/mgo.v2/bson"
+)
+
+type schemaUser struct {
+	Name    string `json:"name"`
+	Email   string
+	Secret  string `json:"-"`
+	balance int
+	Friend  *schemaUser `json:"friend,omitempty"`
+}
+
+var schemaTestID = []byte{0x01, 0x5e, 0x4b, 0x3a, 0xd3, 0x40, 0x7a, 0xbc,
+	0x8d, 0xef, 0x01, 0x23, 0x45, 0x67, 0x89, 0xab}
+
+func schemaTestLog() Log {
+	return Log{
+		ID:      schemaTestID,
+		Time:    time.Date(2009, 11, 10, 23, 0, 0, 5000000, time.UTC),
+		Message: "charged",
+		Level:   NOTICE,
+		Pid:     42,
+		Logger:  &Logger{Name: "payments", Level: DEBUG, DoPanic: true},
+		Data: []interface{}{
+			map[string]interface{}{"amount": 10, "currency": "EUR"},
+			schemaUser{Name: "alice", Email: "a@example.com", Secret: "x", balance: 5},
+			errors.New("card declined"),
+			[]uint8{'h', 'i'},
+			[]int{1, 2},
+			uint(7),
+			1.5,
+			nil,
+		},
+	}
+}
+
+var schemaTestData = []interface{}{
+	map[string]interface{}{"amount": int64(10), "currency": "EUR"},
+	map[string]interface{}{"name": "alice", "Email": "a@example.com", "friend": nil},
+	"card declined",
+	"hi",
+	[]interface{}{int64(1), int64(2)},
+	int64(7),
+	1.5,
+	nil,
+}
+
+func TestLogJSON(t *testing.T) {
+	b, err := json.Marshal(schemaTestLog())
+	assert.Nil(t, err)
+
+	doc := map[string]interface{}{}
+	assert.Nil(t, json.Unmarshal(b, &doc))
+	assert.Equal(t, float64(SchemaVersion), doc["v"])
+	assert.Equal(t, "015e4b3a-d340-7abc-8def-0123456789ab", doc["id"])
+	assert.Equal(t, "2009-11-10T23:00:00.005Z", doc["time"])
+	assert.Equal(t, float64(NOTICE), doc["level"])
+	assert.Equal(t, "NOTICE", doc["level_name"])
+	assert.Equal(t, map[string]interface{}{"name": "payments"}, doc["logger"])
+
+	log := Log{}
+	assert.Nil(t, json.Unmarshal(b, &log))
+	assert.Equal(t, schemaTestID, log.ID)
+	assert.True(t, schemaTestLog().Time.Equal(log.Time))
+	assert.Equal(t, "charged", log.Message)
+	assert.Equal(t, NOTICE, log.Level)
+	assert.Equal(t, 42, log.Pid)
+	assert.Equal(t, &Logger{Name: "payments"}, log.Logger)
+	assert.Equal(t, schemaTestData, log.Data)
+
+	// log without ID, logger and data
+	b, err = json.Marshal(Log{Message: "empty", ID: []byte{1, 2}})
+	assert.Nil(t, err)
+	assert.NotContains(t, string(b), `"id"`)
+	log = Log{}
+	assert.Nil(t, json.Unmarshal(b, &log))
+	assert.Nil(t, log.ID)
+	assert.Nil(t, log.Logger)
+	assert.Nil(t, log.Data)
+}
+
+func TestLogJSONVersions(t *testing.T) {
+	// written before schema had version
+	log := Log{}
+	err := json.Unmarshal([]byte(`{"time":"2009-11-10T23:00:00Z","message":"old",`+
+		`"level":3,"data":[{"id":12345678901234}],"pid":7,"logger":{"name":"legacy"}}`), &log)
+	assert.Nil(t, err)
+	assert.Equal(t, "old", log.Message)
+	assert.Equal(t, ERROR, log.Level)
+	assert.Equal(t, "legacy", log.Logger.Name)
+	assert.Equal(t, []interface{}{map[string]interface{}{"id": int64(12345678901234)}}, log.Data)
+
+	err = json.Unmarshal([]byte(`{"v":2,"message":"future"}`), &log)
+	assert.NotNil(t, err)
+
+	err = json.Unmarshal([]byte(`{"id":"not-an-id","message":"broken"}`), &log)
+	assert.Equal(t, id.ErrInvalid, err)
+}
+
+func TestLogBSON(t *testing.T) {
+	b, err := bson.Marshal(schemaTestLog())
+	assert.Nil(t, err)
+
+	doc := bson.M{}
+	assert.Nil(t, bson.Unmarshal(b, &doc))
+	assert.Equal(t, SchemaVersion, doc["v"])
+	assert.Equal(t, bson.Binary{Kind: 0x04, Data: schemaTestID}, doc["_id"])
+	assert.Equal(t, "NOTICE", doc["level_name"])
+	assert.Equal(t, bson.M{"name": "payments"}, doc["logger"])
+	assert.IsType(t, time.Time{}, doc["time"])
+
+	log := Log{}
+	assert.Nil(t, bson.Unmarshal(b, &log))
+	assert.Equal(t, schemaTestID, log.ID)
+	assert.True(t, schemaTestLog().Time.Equal(log.Time))
+	assert.Equal(t, "charged", log.Message)
+	assert.Equal(t, NOTICE, log.Level)
+	assert.Equal(t, 42, log.Pid)
+	assert.Equal(t, &Logger{Name: "payments"}, log.Logger)
+	assert.Equal(t, schemaTestData, log.Data)
+
+	// stored before logs had IDs
+	b, err = bson.Marshal(bson.M{"_id": bson.NewObjectId(), "message": "old"})
+	assert.Nil(t, err)
+	log = Log{}
+	assert.Nil(t, bson.Unmarshal(b, &log))
+	assert.Nil(t, log.ID)
+	assert.Equal(t, "old", log.Message)
+}
+
+func TestNormalizeCycle(t *testing.T) {
+	user := &schemaUser{Name: "narcissus"}
+	user.Friend = user
+
+	m := map[string]interface{}{}
+	m["self"] = m
+
+	data := normalizeData([]interface{}{user, m})
+	_, err := json.Marshal(data)
+	assert.Nil(t, err)
+
+	// nesting is limited
+	depth := 0
+	for value := data[0]; value != nil; depth++ {
+		value = value.(map[string]interface{})["friend"]
+	}
+	assert.Equal(t, maxDataDepth, depth)
+
+	// pointer to interface which holds the pointer itself
+	var x interface{}
+	x = &x
+	data = normalizeData([]interface{}{x})
+	assert.Equal(t, []interface{}{nil}, data)
+}
@@ -0,0 +1,14 @@
+package golog
+
+import (
+	"os"
+	"syscall"
+	"unsafe"
+)
+
+// terminal reports whether file is terminal
+func terminal(f *os.File) bool {
+	var termios syscall.Termios
+	_, _, errno := syscall.Syscall(syscall.SYS_IOCTL, f.Fd(), syscall.TCGETS, uintptr(unsafe.Pointer(&termios)))
+	return errno == 0
+}